@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/toozej/url2anki/internal/url2anki"
+	"github.com/toozej/url2anki/pkg/config"
+)
+
+// crawlConf holds the crawl subcommand's configuration, copied from conf like the pipeline subcommands'.
+var crawlConf config.Config
+
+// newCrawlCmd creates the crawl subcommand, which discovers pages by following links from
+// the start pages and scrapes every one of them with the same selectors into one deck.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for crawling a site
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki crawl --start https://example.com/docs/ --follow 'a.next, nav.toc a' --max-depth 3 --same-host -q dt -a dd -o deck.apkg
+func newCrawlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "crawl [URL...]",
+		Short:        "Crawl a site and scrape flashcards from every page",
+		Long:         `Follow links from the start pages, breadth first and once per normalized URL, scraping flashcards from every page with the same selectors and exporting them as one deck. Ctrl-C stops the crawl and exports what was collected so far; with --checkpoint, --resume continues where it stopped`,
+		Args:         cobra.ArbitraryArgs,
+		PreRunE:      validateCrawlCmd,
+		RunE:         url2anki.Crawl,
+		SilenceUsage: true,
+	}
+
+	crawlConf = conf
+	addScrapeFlags(cmd, &crawlConf)
+	addExportFlags(cmd, &crawlConf)
+	cmd.Flags().StringArrayVar(&crawlConf.StartURLs, "start", crawlConf.StartURLs, "A page to start crawling from, repeatable (EX: https://kubernetes.io/docs/reference/glossary/)")
+	cmd.Flags().StringVar(&crawlConf.Follow, "follow", crawlConf.Follow, "The HTML selector for the links to follow from every page; rel=\"next\" pagination links are always followed (EX: 'a.next, nav.toc a')")
+	cmd.Flags().IntVar(&crawlConf.MaxDepth, "max-depth", crawlConf.MaxDepth, "How many followed links away from a start page to crawl")
+	cmd.Flags().IntVar(&crawlConf.MaxPages, "max-pages", crawlConf.MaxPages, "The most pages to fetch (0 for no limit)")
+	cmd.Flags().BoolVar(&crawlConf.SameHost, "same-host", crawlConf.SameHost, "Only follow links to the hosts of the start pages")
+	cmd.Flags().StringVar(&crawlConf.Checkpoint, "checkpoint", crawlConf.Checkpoint, "Record the queued and visited pages and the cards collected so far in this file after every page (EX: .url2anki-crawl.json)")
+	cmd.Flags().BoolVar(&crawlConf.Resume, "resume", crawlConf.Resume, "Continue the crawl recorded in the --checkpoint file instead of starting over")
+
+	return cmd
+}
+
+// validateCrawlCmd checks the crawl, extraction and output flags before any page is fetched.
+//
+// Parameters:
+//   - cmd: The cobra command being executed
+//   - args: Command-line arguments, further start pages
+//
+// Returns:
+//   - error: The first invalid flag combination found, if any
+func validateCrawlCmd(cmd *cobra.Command, args []string) error {
+	if err := url2anki.ValidateCrawl(cmd, args); err != nil {
+		return err
+	}
+	return url2anki.ValidateOutputs(cmd, args)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/toozej/url2anki/internal/url2anki"
+)
+
+// newMergeCmd creates the merge subcommand, which re-applies a fresh scrape to a hand-edited export.
+//
+// The command performs a three-way merge between the last scraped baseline,
+// the user-edited file and a fresh scrape, all in the JSON or CSV formats
+// written by url2anki. Upstream changes are applied to cards the user did not
+// touch, user edits are kept, and cards changed differently on both sides are
+// written to a conflict file for review.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for merging flashcard files
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki merge --base last.json --edited deck.json --fresh new.json -o deck.json
+func newMergeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "merge",
+		Short:        "Merge a fresh scrape into hand-edited flashcards",
+		Long:         `Three-way merge a fresh scrape into a hand-edited JSON or CSV export, keeping user edits and writing real conflicts to a reviewable file`,
+		Args:         cobra.NoArgs,
+		RunE:         url2anki.Merge,
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().String("base", "", "The previously scraped flashcards the edits started from")
+	cmd.Flags().String("edited", "", "The hand-edited flashcards")
+	cmd.Flags().String("fresh", "", "The freshly scraped flashcards")
+	cmd.Flags().StringP("output-file", "o", "", "The filename to write the merged flashcards to (defaults to the edited file)")
+	cmd.Flags().String("conflicts", "merge-conflicts.json", "The filename to write conflicting cards to")
+
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "base")
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "edited")
+	_ = cobra.MarkFlagRequired(cmd.Flags(), "fresh")
+
+	return cmd
+}
@@ -45,9 +45,12 @@ var rootCmd = &cobra.Command{
 	Use:              "url2anki",
 	Short:            "Generate Anki flashcards from a URL",
 	Long:             `Generate Anki-formatted flashcards from a given URL and export them to a file to be imported into Anki`,
-	Args:             cobra.ExactArgs(0),
+	Args:             cobra.ArbitraryArgs,
 	PersistentPreRun: rootCmdPreRun,
-	Run:              rootCmdRun,
+	PreRunE:          validateScrapeCmd,
+	RunE:             rootCmdRun,
+	// Execute prints errors itself, to stderr, so they are not reported twice
+	SilenceErrors: true,
 }
 
 // rootCmdRun is the main execution function for the root command.
@@ -55,9 +58,14 @@ var rootCmd = &cobra.Command{
 //
 // Parameters:
 //   - cmd: The cobra command being executed
-//   - args: Command-line arguments (unused, as root command takes no args)
-func rootCmdRun(cmd *cobra.Command, args []string) {
-	url2anki.Run(cmd, args)
+//   - args: Command-line arguments, the URLs to scrape alongside --url
+//
+// Returns:
+//   - error: Any failure while scraping or exporting, which makes the process exit non-zero
+func rootCmdRun(cmd *cobra.Command, args []string) error {
+	// The flags were valid, so failures from here on are not usage errors
+	cmd.SilenceUsage = true
+	return url2anki.Run(cmd, args)
 }
 
 // rootCmdPreRun performs setup operations before executing the root command.
@@ -78,9 +86,9 @@ func rootCmdPreRun(cmd *cobra.Command, args []string) {
 // Execute starts the command-line interface execution.
 // This is the main entry point called from main.go to begin command processing.
 //
-// If command execution fails, it prints the error message to stdout and
-// exits the program with status code 1. This follows standard Unix conventions
-// for command-line tool error handling.
+// If command execution fails, it prints the error message to stderr, keeping
+// stdout clean for the card stream, and exits the program with status code 1.
+// This follows standard Unix conventions for command-line tool error handling.
 //
 // Example:
 //
@@ -89,7 +97,7 @@ func rootCmdPreRun(cmd *cobra.Command, args []string) {
 //	}
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err.Error())
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
 }
@@ -100,7 +108,7 @@ func Execute() {
 //   - Loads configuration from environment variables using config.GetEnvVars()
 //   - Defines persistent flags that are available to all commands
 //   - Sets up command-specific flags for the root command
-//   - Registers subcommands (man pages and version information)
+//   - Registers subcommands (merge, crawl, the scrape/filter/transform/export pipeline, man pages and version information)
 //   - Marks required flags for proper validation
 //
 // The debug flag (-d, --debug) enables debug-level logging and is persistent,
@@ -108,9 +116,9 @@ func Execute() {
 // configuration values from environment variables or .env files.
 //
 // Required flags:
-//   - url: The URL to scrape for flashcards
-//   - question-selector: HTML selector for questions
-//   - answer-selector: HTML selector for answers
+//   - url, url-file, sitemap or URL arguments: At least one page to scrape for flashcards
+//   - question-selector and answer-selector: HTML selectors for questions and answers,
+//     unless --mode picks a built-in extractor
 func init() {
 	// get configuration from environment variables
 	conf = config.GetEnvVars()
@@ -119,20 +127,93 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", conf.Debug, "Enable debug-level logging")
 
 	// CLI flags that can override environment variables
-	rootCmd.Flags().StringVarP(&conf.URL, "url", "u", conf.URL, "The URL to scrape for flashcards (EX: https://kubernetes.io/docs/reference/glossary/?all=true)")
-	rootCmd.Flags().StringVarP(&conf.QuestionSelector, "question-selector", "q", conf.QuestionSelector, "The HTML selector for the questions (EX: div.term-name)")
-	rootCmd.Flags().StringVarP(&conf.AnswerSelector, "answer-selector", "a", conf.AnswerSelector, "The HTML selector for the answers (EX: div.term-definition)")
-	rootCmd.Flags().StringVarP(&conf.OutputFile, "output-file", "o", conf.OutputFile, "The filename (including extension) to export flashcards to")
-	rootCmd.Flags().BoolVarP(&conf.Preview, "preview", "p", conf.Preview, "Preview the flashcards before exporting")
-
-	// Mark required flags
-	_ = cobra.MarkFlagRequired(rootCmd.Flags(), "url")
-	_ = cobra.MarkFlagRequired(rootCmd.Flags(), "question-selector")
-	_ = cobra.MarkFlagRequired(rootCmd.Flags(), "answer-selector")
+	addScrapeFlags(rootCmd, &conf)
+	addExportFlags(rootCmd, &conf)
 
 	// add sub-commands
 	rootCmd.AddCommand(
+		newMergeCmd(),
+		newScrapeCmd(),
+		newCrawlCmd(),
+		newFilterCmd(),
+		newTransformCmd(),
+		newExportCmd(),
 		man.NewManCmd(),
 		version.Command(),
 	)
 }
+
+// addScrapeFlags defines the flags that control what is scraped from the pages, bound to c.
+//
+// Parameters:
+//   - cmd: The cobra command to define the flags on
+//   - c: The configuration the flags override
+func addScrapeFlags(cmd *cobra.Command, c *config.Config) {
+	cmd.Flags().StringArrayVarP(&c.URLs, "url", "u", c.URLs, "A URL to scrape for flashcards, repeatable; URLs may also be given as arguments (EX: https://kubernetes.io/docs/reference/glossary/?all=true)")
+	cmd.Flags().StringVar(&c.URLFile, "url-file", c.URLFile, "A file listing further URLs to scrape with the same selectors, one per line; blank lines and # comments are ignored")
+	cmd.Flags().StringVar(&c.Sitemap, "sitemap", c.Sitemap, "A sitemap.xml, sitemap index or gzipped sitemap listing further URLs to scrape; with --state, pages whose lastmod predates the previous run keep their cards without being refetched (EX: https://kubernetes.io/sitemap.xml)")
+	cmd.Flags().StringVar(&c.URLPattern, "url-pattern", c.URLPattern, "Only scrape the sitemap URLs matching this regular expression (EX: '/docs/reference/glossary/.*')")
+	cmd.Flags().StringVar(&c.Since, "since", c.Since, "Only scrape the sitemap URLs whose lastmod is this recent, as a date, timestamp or duration (EX: 2026-01-01, 7d)")
+	cmd.Flags().StringVar(&c.Mode, "mode", c.Mode, "How to pick flashcards out of the page: selectors pairs --question-selector and --answer-selector, dl reads <dl> definition lists, details reads <details>/<summary> FAQs, table reads table rows, sections reads headings and the content below them, inline-terms reads <abbr>, <dfn> and tooltips in prose")
+	cmd.Flags().StringVarP(&c.QuestionSelector, "question-selector", "q", c.QuestionSelector, "The HTML selector for the questions, required in selectors mode; append @attr to read an attribute, join several with && and prefix XPath with xpath: (EX: div.term-name, abbr@title, xpath://h2)")
+	cmd.Flags().StringVarP(&c.AnswerSelector, "answer-selector", "a", c.AnswerSelector, "The HTML selector for the answers, required in selectors mode; takes @attr, && and xpath: like --question-selector (EX: div.term-definition, xpath://h2/following-sibling::p[1])")
+	cmd.Flags().StringVar(&c.GUIDSelector, "guid-selector", c.GUIDSelector, "The HTML selector whose text keys each flashcard's stable GUID, paired with questions by index (defaults to the question text)")
+	cmd.Flags().StringVarP(&c.ItemSelector, "item-selector", "i", c.ItemSelector, "The HTML selector or xpath: expression for each flashcard's container element; the other selectors are then evaluated inside it (EX: div.glossary-entry)")
+	cmd.Flags().StringVar(&c.LinkSelector, "link-selector", c.LinkSelector, "The HTML selector for the links on an index page whose text (or @attr) becomes each question; the answer comes from the page each link points to (EX: 'ul.terms a')")
+	cmd.Flags().StringVar(&c.DetailAnswerSelector, "detail-answer-selector", c.DetailAnswerSelector, "The HTML selector for the answer on each page a --link-selector link points to; takes @attr, && and xpath: like --answer-selector (EX: 'article p:first-of-type')")
+	cmd.Flags().IntVar(&c.DetailConcurrency, "detail-concurrency", c.DetailConcurrency, "How many linked pages to fetch at once with --link-selector")
+	cmd.Flags().StringVar(&c.TableSelector, "table-selector", c.TableSelector, "The HTML selector for the tables read in table mode (EX: table.flags)")
+	cmd.Flags().StringVar(&c.QuestionColumn, "question-col", c.QuestionColumn, "The table column holding questions in table mode, as a number from 1 or header text")
+	cmd.Flags().StringVar(&c.AnswerColumn, "answer-col", c.AnswerColumn, "The table column holding answers in table mode, as a number from 1 or header text")
+	cmd.Flags().StringArrayVar(&c.ExtraColumns, "extra-col", c.ExtraColumns, "A table column appended to the answer as \"Header: value\" in table mode, repeatable")
+	cmd.Flags().StringArrayVar(&c.TagColumns, "tag-col", c.TagColumns, "A table column whose values become tags in table mode, repeatable")
+	cmd.Flags().StringVar(&c.HeadingSelector, "heading", c.HeadingSelector, "The headings turned into questions in sections mode; each answer runs to the next heading of the same or a higher level")
+	cmd.Flags().BoolVar(&c.MainContent, "main-content", c.MainContent, "Isolate the page's main article content first, dropping navigation, footers, cookie banners and other boilerplate")
+	cmd.Flags().StringVar(&c.FieldSeparator, "field-separator", c.FieldSeparator, "The separator between the values of selector parts joined with &&")
+	cmd.Flags().StringArrayVar(&c.Excludes, "exclude", c.Excludes, "An HTML selector for elements to leave out of questions and answers, repeatable (EX: --exclude a.edit --exclude sup.reference)")
+	cmd.Flags().BoolVar(&c.KeepHidden, "keep-hidden", c.KeepHidden, "Keep hidden, aria-hidden and screen-reader-only elements in questions and answers")
+	cmd.Flags().BoolVar(&c.Tolerant, "tolerant", c.Tolerant, "Pair each question with the next answer in document order, skipping and reporting the ones that do not pair up")
+	cmd.Flags().Bool("diagnose", false, "Report the question and answer counts and where their sequence breaks, without exporting")
+	cmd.Flags().StringVar(&c.StateFile, "state", c.StateFile, "Remember scraped cards in this file and report what was added, changed or removed since the previous run (EX: .url2anki-state.json)")
+	cmd.Flags().BoolVar(&c.OnlyChanges, "only-changes", c.OnlyChanges, "With --state, export only the cards added or changed since the previous run")
+	cmd.Flags().BoolVar(&c.TagChanges, "tag-changes", c.TagChanges, "With --state, tag changed cards, and tag the notes of removed cards for review in --collection, --anki-connect and --sync-server without adding them to files")
+	cmd.Flags().BoolVarP(&c.Preview, "preview", "p", c.Preview, "Preview the flashcards before exporting")
+}
+
+// validateScrapeCmd checks the extraction and output flags before any scraping happens.
+//
+// Parameters:
+//   - cmd: The cobra command being executed
+//   - args: Command-line arguments
+//
+// Returns:
+//   - error: The first invalid flag combination found, if any
+func validateScrapeCmd(cmd *cobra.Command, args []string) error {
+	if err := url2anki.ValidateScrape(cmd, args); err != nil {
+		return err
+	}
+	return url2anki.ValidateOutputs(cmd, args)
+}
+
+// addExportFlags defines the flags that control where flashcards are delivered, bound to c.
+//
+// Parameters:
+//   - cmd: The cobra command to define the flags on
+//   - c: The configuration the flags override
+func addExportFlags(cmd *cobra.Command, c *config.Config) {
+	cmd.Flags().StringVarP(&c.OutputFile, "output-file", "o", c.OutputFile, "The filename (including extension) to export flashcards to, or - to stream JSON Lines to stdout")
+	cmd.Flags().StringArrayVar(&c.Outputs, "output", c.Outputs, "An additional filename to export flashcards to, repeatable (EX: --output deck.apkg --output deck.csv)")
+	cmd.Flags().StringVar(&c.Format, "format", c.Format, "The export format of a single output, overriding detection by file extension (json, jsonl, csv, apkg, txt)")
+	cmd.Flags().StringVar(&c.Delimiter, "delimiter", c.Delimiter, "The field delimiter for CSV and text exports: a single character or tab, comma, semicolon, space, pipe, colon")
+	cmd.Flags().StringVar(&c.Quote, "quote", c.Quote, "The quoting style for CSV and text exports: minimal, all or none")
+	cmd.Flags().BoolVar(&c.BOM, "bom", c.BOM, "Start CSV and text exports with a UTF-8 byte order mark")
+	cmd.Flags().BoolVar(&c.NoHeader, "no-header", c.NoHeader, "Leave out the header row of CSV and text exports")
+	cmd.Flags().StringVar(&c.Deck, "deck", c.Deck, "The Anki deck name for .apkg and .txt exports (defaults to the title of the first page scraped)")
+	cmd.Flags().StringVar(&c.Collection, "collection", c.Collection, "Write flashcards straight into an existing Anki collection.anki2 file while Anki is closed")
+	cmd.Flags().StringVar(&c.AnkiConnect, "anki-connect", c.AnkiConnect, "Push flashcards to a running Anki via AnkiConnect (EX: http://127.0.0.1:8765)")
+	cmd.Flags().BoolVar(&c.UpdateExisting, "update-existing", c.UpdateExisting, "Update existing notes whose answer changed instead of skipping them as duplicates")
+	cmd.Flags().StringVar(&c.SyncServer, "sync-server", c.SyncServer, "Upload flashcards to a self-hosted Anki sync server (EX: http://127.0.0.1:8080/); requires --force-full-upload")
+	cmd.Flags().BoolVar(&c.ForceFullUpload, "force-full-upload", c.ForceFullUpload, "Allow --sync-server to replace the server's whole collection; changes another device syncs while url2anki runs are lost, so sync every device first")
+	cmd.Flags().StringVar(&c.SyncUser, "sync-user", c.SyncUser, "The username for the sync server")
+	cmd.Flags().StringVar(&c.SyncPassword, "sync-password", c.SyncPassword, "The password for the sync server (prefer the URL2ANKI_SYNC_PASSWORD environment variable)")
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/toozej/url2anki/internal/url2anki"
+	"github.com/toozej/url2anki/pkg/config"
+)
+
+// Configuration for the pipeline subcommands, copied from conf so their flags
+// can default differently from the root command's without clobbering it.
+var (
+	scrapeConf config.Config
+	exportConf config.Config
+)
+
+// newScrapeCmd creates the scrape subcommand, the first stage of a card pipeline.
+//
+// It accepts the same flags as the root command, but streams the scraped
+// flashcards to stdout as JSON Lines unless told otherwise, with progress
+// messages going to stderr.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for scraping flashcards
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki scrape -u https://example.com/glossary -q dt -a dd | ./url2anki export -o deck.apkg
+func newScrapeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "scrape [URL...]",
+		Short:        "Scrape flashcards to a JSON Lines stream",
+		Long:         `Scrape flashcards from one or more URLs and write them to stdout as JSON Lines, one card per line, for filter, transform and export to consume`,
+		Args:         cobra.ArbitraryArgs,
+		PreRunE:      validateScrapeCmd,
+		RunE:         url2anki.Run,
+		SilenceUsage: true,
+	}
+
+	scrapeConf = conf
+	scrapeConf.OutputFile = "-"
+	addScrapeFlags(cmd, &scrapeConf)
+	addExportFlags(cmd, &scrapeConf)
+
+	return cmd
+}
+
+// newFilterCmd creates the filter subcommand, which drops cards from a JSON Lines stream.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for filtering flashcards
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki scrape ... | ./url2anki filter --min-len 10 --not-match '(?i)deprecated' | ./url2anki export -o deck.apkg
+func newFilterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "filter",
+		Short:        "Filter a JSON Lines flashcard stream",
+		Long:         `Read JSON Lines flashcards from stdin and write the ones that pass every filter to stdout`,
+		Args:         cobra.NoArgs,
+		RunE:         url2anki.Filter,
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().Int("min-len", 0, "Drop cards whose answer is shorter than this many characters")
+	cmd.Flags().Int("max-len", 0, "Drop cards whose answer is longer than this many characters (0 for no limit)")
+	cmd.Flags().String("match", "", "Keep only cards whose question or answer matches this regular expression")
+	cmd.Flags().String("not-match", "", "Drop cards whose question or answer matches this regular expression")
+	cmd.Flags().StringArray("tag", nil, "Keep only cards carrying this tag, repeatable")
+	cmd.Flags().Bool("dedupe", false, "Drop cards with the same GUID or question as an earlier card")
+
+	return cmd
+}
+
+// newTransformCmd creates the transform subcommand, which rewrites cards in a JSON Lines stream.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for transforming flashcards
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki scrape ... | ./url2anki transform --replace '\s*\[\d+\]=' --add-tag k8s | ./url2anki export -o deck.apkg
+func newTransformCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "transform",
+		Short:        "Transform a JSON Lines flashcard stream",
+		Long:         `Read JSON Lines flashcards from stdin, rewrite them and write them to stdout`,
+		Args:         cobra.NoArgs,
+		RunE:         url2anki.Transform,
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringArray("replace", nil, "Replace matches of a regular expression in questions and answers, written pattern=replacement, repeatable")
+	cmd.Flags().Int("truncate", 0, "Cut answers longer than this many characters (0 for no limit)")
+	cmd.Flags().Bool("swap", false, "Swap each card's question and answer")
+	cmd.Flags().StringArray("add-tag", nil, "Add this tag to every card, repeatable")
+
+	return cmd
+}
+
+// newExportCmd creates the export subcommand, the last stage of a card pipeline.
+//
+// It reads JSON Lines flashcards from stdin and delivers them with the same
+// output, deck, collection, AnkiConnect and sync server flags as the root command.
+//
+// Returns:
+//   - *cobra.Command: A configured cobra command for exporting flashcards
+//
+// Example:
+//
+//	// Usage from command line:
+//	// ./url2anki scrape ... | ./url2anki export -o deck.apkg --deck Glossary
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "export",
+		Short:        "Export a JSON Lines flashcard stream",
+		Long:         `Read JSON Lines flashcards from stdin and export them to files, an Anki collection, AnkiConnect or a sync server`,
+		Args:         cobra.NoArgs,
+		PreRunE:      url2anki.ValidateOutputs,
+		RunE:         url2anki.Export,
+		SilenceUsage: true,
+	}
+
+	exportConf = conf
+	addExportFlags(cmd, &exportConf)
+	cmd.Flags().Bool("allow-empty", false, "Export an empty card stream instead of failing, replacing the contents of every output")
+
+	return cmd
+}
@@ -0,0 +1,745 @@
+package url2anki
+
+import (
+	"crypto/sha1" // #nosec G505 -- Anki's note checksum is defined as SHA-1
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite" // registers the pure-Go "sqlite" database/sql driver
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// ankiSchemaVersion is the legacy collection schema understood by every Anki release
+	ankiSchemaVersion = 11
+	// ankiTableSchemaVersion is the first schema storing decks and note types in their own tables
+	ankiTableSchemaVersion = 15
+	// stockBasicModelName is the note type every modern Anki collection ships with
+	stockBasicModelName = "Basic"
+	// ankiFieldSeparator separates the field values stored in notes.flds
+	ankiFieldSeparator = "\x1f"
+	// basicModelID is fixed so repeated imports reuse the same note type instead of cloning it
+	basicModelID int64 = 1700000000000
+	// basicModelName is the name of the note type url2anki creates
+	basicModelName = "Basic (url2anki)"
+	// defaultDeckID is the id of Anki's built-in "Default" deck
+	defaultDeckID int64 = 1
+)
+
+// Templates and styling of url2anki's Basic note type, matching Anki's stock Basic
+const (
+	basicModelQuestionFormat = "{{Front}}"
+	basicModelAnswerFormat   = "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"
+	basicModelCSS            = ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n color: black;\n background-color: white;\n}\n"
+	basicModelLatexPre       = "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n"
+	basicModelLatexPost      = "\\end{document}"
+)
+
+// ankiSchema creates the tables and indexes of a schema 11 Anki collection
+const ankiSchema = `
+CREATE TABLE col (
+	id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+	conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+	id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null,
+	csum integer not null, flags integer not null, data text not null
+);
+CREATE TABLE cards (
+	id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null,
+	due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+	lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+	flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+	id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+	type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// errCollectionLocked is returned when another process, usually a running Anki, holds the collection open
+var errCollectionLocked = errors.New("the collection is locked, close Anki and try again")
+
+// normalDeckKind is the protobuf-encoded DeckKind of a regular deck using options group 1
+var normalDeckKind = []byte{0x0a, 0x02, 0x08, 0x01}
+
+func init() {
+	// Modern collections index deck and note type names with Anki's case-insensitive "unicase" collation
+	sqlite.MustRegisterCollationUtf8("unicase", func(left, right string) int {
+		return strings.Compare(strings.ToLower(left), strings.ToLower(right))
+	})
+}
+
+// ankiCollection is an open Anki collection database that url2anki can add notes to
+type ankiCollection struct {
+	db      *sql.DB
+	schema  int
+	now     time.Time
+	usn     int
+	lastID  int64
+	nextPos int64
+	locked  bool
+}
+
+// noteChange describes what upsertNote did with a flashcard
+type noteChange int
+
+const (
+	noteUnchanged noteChange = iota
+	noteAdded
+	noteUpdated
+)
+
+// collectionReport summarises the notes written into an existing collection
+type collectionReport struct {
+	Added     int
+	Updated   int
+	Unchanged int
+}
+
+// newAnkiCollection creates an empty collection at path containing only the Default deck
+func newAnkiCollection(path string) (*ankiCollection, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	c := &ankiCollection{db: db, schema: ankiSchemaVersion, now: time.Now(), usn: -1, nextPos: 1}
+
+	if _, err := db.Exec(ankiSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating collection schema: %w", err)
+	}
+
+	decks, _ := json.Marshal(map[string]map[string]any{
+		strconv.FormatInt(defaultDeckID, 10): newAnkiDeck(defaultDeckID, "Default", c.now),
+	})
+	dconf, _ := json.Marshal(map[string]map[string]any{"1": defaultAnkiDeckConfig()})
+	conf, _ := json.Marshal(map[string]any{
+		"activeDecks":   []int64{defaultDeckID},
+		"curDeck":       defaultDeckID,
+		"newSpread":     0,
+		"collapseTime":  1200,
+		"timeLim":       0,
+		"estTimes":      true,
+		"dueCounts":     true,
+		"curModel":      nil,
+		"nextPos":       1,
+		"sortType":      "noteFld",
+		"sortBackwards": false,
+		"addToCur":      true,
+	})
+
+	_, err = db.Exec(
+		`INSERT INTO col (id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags)
+		VALUES (1, ?, ?, ?, ?, 0, 0, 0, ?, '{}', ?, ?, '{}')`,
+		c.now.Unix(), c.now.UnixMilli(), c.now.UnixMilli(), ankiSchemaVersion,
+		string(conf), string(decks), string(dconf),
+	)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialising collection: %w", err)
+	}
+
+	return c, nil
+}
+
+// openAnkiCollection opens the existing collection at path and locks it for the rest of the session
+func openAnkiCollection(path string) (*ankiCollection, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps every statement inside the exclusive transaction taken by lock
+	db.SetMaxOpenConns(1)
+	c := &ankiCollection{db: db, now: time.Now(), usn: -1, nextPos: 1}
+
+	if err := c.lock(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.QueryRow("SELECT ver FROM col").Scan(&c.schema); err != nil {
+		c.discard()
+		return nil, fmt.Errorf("reading collection schema version: %w", err)
+	}
+
+	// Carry on numbering new cards from where Anki left off
+	var nextPos []byte
+	if c.schema >= ankiTableSchemaVersion {
+		err = db.QueryRow("SELECT val FROM config WHERE KEY = 'nextPos'").Scan(&nextPos)
+	} else {
+		var conf string
+		if err = db.QueryRow("SELECT conf FROM col").Scan(&conf); err == nil {
+			var values struct {
+				NextPos json.RawMessage `json:"nextPos"`
+			}
+			err = json.Unmarshal([]byte(conf), &values)
+			nextPos = values.NextPos
+		}
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.discard()
+		return nil, fmt.Errorf("reading collection config: %w", err)
+	}
+	if pos, err := strconv.ParseInt(string(nextPos), 10, 64); err == nil && pos > 0 {
+		c.nextPos = pos
+	}
+
+	return c, nil
+}
+
+// lock takes an exclusive lock on the collection, failing with errCollectionLocked when Anki has it open.
+// Changes made while locked are committed by Close and thrown away by discard.
+func (c *ankiCollection) lock() error {
+	if _, err := c.db.Exec("PRAGMA busy_timeout = 0"); err != nil {
+		return err
+	}
+	if _, err := c.db.Exec("BEGIN EXCLUSIVE"); err != nil {
+		if isSQLiteBusy(err) {
+			return errCollectionLocked
+		}
+		return fmt.Errorf("locking collection: %w", err)
+	}
+	c.locked = true
+	return nil
+}
+
+// isSQLiteBusy reports whether err means another connection holds a conflicting lock
+func isSQLiteBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// discard rolls back any changes made since lock and closes the database
+func (c *ankiCollection) discard() error {
+	if c.locked {
+		_, _ = c.db.Exec("ROLLBACK")
+		c.locked = false
+	}
+	return c.db.Close()
+}
+
+// Close records the collection's modification time and new card position, then closes the database
+func (c *ankiCollection) Close() error {
+	if c.schema >= ankiTableSchemaVersion {
+		_, err := c.db.Exec(
+			"INSERT OR REPLACE INTO config (KEY, usn, mtime_secs, val) VALUES ('nextPos', ?, ?, ?)",
+			c.usn, c.now.Unix(), []byte(strconv.FormatInt(c.nextPos, 10)),
+		)
+		if err != nil {
+			c.db.Close()
+			return fmt.Errorf("writing collection config: %w", err)
+		}
+	} else {
+		var raw string
+		if err := c.db.QueryRow("SELECT conf FROM col").Scan(&raw); err != nil {
+			c.db.Close()
+			return fmt.Errorf("reading collection config: %w", err)
+		}
+		conf := map[string]any{}
+		if err := json.Unmarshal([]byte(raw), &conf); err != nil {
+			c.db.Close()
+			return fmt.Errorf("decoding collection config: %w", err)
+		}
+		conf["nextPos"] = c.nextPos
+		data, _ := json.Marshal(conf)
+		if _, err := c.db.Exec("UPDATE col SET conf = ?", string(data)); err != nil {
+			c.db.Close()
+			return fmt.Errorf("writing collection config: %w", err)
+		}
+	}
+
+	if _, err := c.db.Exec("UPDATE col SET mod = ?", c.now.UnixMilli()); err != nil {
+		c.db.Close()
+		return fmt.Errorf("writing collection modification time: %w", err)
+	}
+
+	if c.locked {
+		if _, err := c.db.Exec("COMMIT"); err != nil {
+			c.db.Close()
+			return fmt.Errorf("committing collection: %w", err)
+		}
+		c.locked = false
+	}
+
+	return c.db.Close()
+}
+
+// prepareForUpload stamps every pending change with the server's current update sequence number and
+// advances it, the way the server records a normal sync, so other clients pull the changes incrementally.
+// The schema modification time is left alone, since bumping it would force every device into a full sync.
+func (c *ankiCollection) prepareForUpload() error {
+	var serverUSN int
+	if err := c.db.QueryRow("SELECT usn FROM col").Scan(&serverUSN); err != nil {
+		return fmt.Errorf("reading collection update sequence number: %w", err)
+	}
+
+	tables := []string{"notes", "cards"}
+	if c.schema >= ankiTableSchemaVersion {
+		tables = append(tables, "decks", "notetypes", "config")
+	}
+	for _, table := range tables {
+		if _, err := c.db.Exec("UPDATE "+table+" SET usn = ? WHERE usn = -1", serverUSN); err != nil { // #nosec G202 -- table is one of a fixed set of names
+			return fmt.Errorf("preparing collection for upload: %w", err)
+		}
+	}
+	if c.schema < ankiTableSchemaVersion {
+		// Legacy collections keep decks and note types as JSON in the col table
+		for _, column := range []string{"decks", "models"} {
+			values, err := c.loadColJSON(column)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				if usn, _ := value["usn"].(float64); usn == -1 {
+					value["usn"] = serverUSN
+				}
+			}
+			if err := c.saveColJSON(column, values); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := c.db.Exec("UPDATE col SET usn = ?, ls = ?", serverUSN+1, c.now.UnixMilli()); err != nil {
+		return fmt.Errorf("preparing collection for upload: %w", err)
+	}
+	c.usn = serverUSN
+	return nil
+}
+
+// nextID returns a millisecond-timestamp id that is unique within this session
+func (c *ankiCollection) nextID() int64 {
+	id := time.Now().UnixMilli()
+	if id <= c.lastID {
+		id = c.lastID + 1
+	}
+	c.lastID = id
+	return id
+}
+
+// ensureDeck returns the id of the deck called name, creating it if it does not exist yet
+func (c *ankiCollection) ensureDeck(name string) (int64, error) {
+	if c.schema >= ankiTableSchemaVersion {
+		// Deck tables separate nested deck names with 0x1f rather than "::"
+		tableName := strings.ReplaceAll(name, "::", ankiFieldSeparator)
+		var id int64
+		err := c.db.QueryRow("SELECT id FROM decks WHERE name = ?", tableName).Scan(&id)
+		if err == nil || !errors.Is(err, sql.ErrNoRows) {
+			return id, err
+		}
+
+		id = c.nextID()
+		_, err = c.db.Exec(
+			"INSERT INTO decks (id, name, mtime_secs, usn, common, kind) VALUES (?, ?, ?, ?, ?, ?)",
+			id, tableName, c.now.Unix(), c.usn, []byte{}, normalDeckKind,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("creating deck %q: %w", name, err)
+		}
+		return id, nil
+	}
+
+	decks, err := c.loadColJSON("decks")
+	if err != nil {
+		return 0, err
+	}
+	for id, deck := range decks {
+		if deckName, _ := deck["name"].(string); deckName == name {
+			return strconv.ParseInt(id, 10, 64)
+		}
+	}
+
+	id := c.nextID()
+	decks[strconv.FormatInt(id, 10)] = newAnkiDeck(id, name, c.now)
+	return id, c.saveColJSON("decks", decks)
+}
+
+// ensureBasicModel returns the id of url2anki's Basic note type, creating it if it does not exist yet.
+// Modern collections reuse their stock Basic note type when they have one.
+func (c *ankiCollection) ensureBasicModel(deckID int64) (int64, error) {
+	if c.schema >= ankiTableSchemaVersion {
+		var id int64
+		err := c.db.QueryRow(
+			"SELECT id FROM notetypes WHERE name IN (?, ?) ORDER BY name = ? DESC LIMIT 1",
+			basicModelName, stockBasicModelName, basicModelName,
+		).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.createBasicNotetype()
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var fieldCount int
+		if err := c.db.QueryRow("SELECT count(*) FROM fields WHERE ntid = ?", id).Scan(&fieldCount); err != nil {
+			return 0, err
+		}
+		if fieldCount != 2 {
+			return 0, fmt.Errorf("the %q note type has %d fields, expected 2", stockBasicModelName, fieldCount)
+		}
+		return id, nil
+	}
+
+	models, err := c.loadColJSON("models")
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := models[strconv.FormatInt(basicModelID, 10)]; ok {
+		return basicModelID, nil
+	}
+
+	models[strconv.FormatInt(basicModelID, 10)] = newBasicModel(deckID, c.now)
+	return basicModelID, c.saveColJSON("models", models)
+}
+
+// createBasicNotetype adds url2anki's Front/Back note type to a modern collection's notetypes, fields
+// and templates tables, with the protobuf-encoded configs Anki stores alongside each row
+func (c *ankiCollection) createBasicNotetype() (int64, error) {
+	id := c.nextID()
+	config := slices.Concat(
+		protoString(3, basicModelCSS),
+		protoString(5, basicModelLatexPre),
+		protoString(6, basicModelLatexPost),
+	)
+	_, err := c.db.Exec(
+		"INSERT INTO notetypes (id, name, mtime_secs, usn, config) VALUES (?, ?, ?, ?, ?)",
+		id, basicModelName, c.now.Unix(), c.usn, config,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("creating note type %q: %w", basicModelName, err)
+	}
+
+	fieldConfig := slices.Concat(protoString(3, "Arial"), protoVarint(4, 20))
+	for ord, name := range []string{"Front", "Back"} {
+		_, err := c.db.Exec(
+			"INSERT INTO fields (ntid, ord, name, config) VALUES (?, ?, ?, ?)",
+			id, ord, name, fieldConfig,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("creating note type %q: %w", basicModelName, err)
+		}
+	}
+
+	templateConfig := slices.Concat(protoString(1, basicModelQuestionFormat), protoString(2, basicModelAnswerFormat))
+	_, err = c.db.Exec(
+		"INSERT INTO templates (ntid, ord, name, mtime_secs, usn, config) VALUES (?, 0, 'Card 1', ?, ?, ?)",
+		id, c.now.Unix(), c.usn, templateConfig,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("creating note type %q: %w", basicModelName, err)
+	}
+	return id, nil
+}
+
+// addNote inserts a Basic note for the flashcard and its single card into the given deck
+func (c *ankiCollection) addNote(modelID, deckID int64, flashcard Flashcard) error {
+	noteID := c.nextID()
+	fields := []string{ankiFieldHTML(flashcard.Question), ankiFieldHTML(flashcard.Answer)}
+	sortField := ankiSortField(fields[0])
+
+	_, err := c.db.Exec(
+		`INSERT INTO notes (id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, '')`,
+		noteID, noteGUID(flashcard), modelID, c.now.Unix(), c.usn, noteTags(flashcard.Tags),
+		strings.Join(fields, ankiFieldSeparator), sortField, fieldChecksum(sortField),
+	)
+	if err != nil {
+		return fmt.Errorf("inserting note %q: %w", flashcard.Question, err)
+	}
+
+	// New cards are due in insertion order, which Anki tracks as a position in the new queue
+	_, err = c.db.Exec(
+		`INSERT INTO cards (id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid, flags, data)
+		VALUES (?, ?, ?, 0, ?, ?, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+		c.nextID(), noteID, deckID, c.now.Unix(), c.usn, c.nextPos,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting card %q: %w", flashcard.Question, err)
+	}
+	c.nextPos++
+
+	return nil
+}
+
+// upsertNote adds the flashcard to the deck, or updates the note with the same GUID, or failing that,
+// the note in the deck with the same question. Cards removed upstream only tag such a note.
+func (c *ankiCollection) upsertNote(modelID, deckID int64, flashcard Flashcard) (noteChange, error) {
+	guid := noteGUID(flashcard)
+	question := ankiFieldHTML(flashcard.Question)
+	sortField := ankiSortField(question)
+
+	var noteID int64
+	var existingGUID, flds, tags string
+	err := c.db.QueryRow("SELECT id, guid, flds, tags FROM notes WHERE guid = ? AND mid = ?", guid, modelID).Scan(&noteID, &existingGUID, &flds, &tags)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = c.db.QueryRow(
+			`SELECT n.id, n.guid, n.flds, n.tags FROM notes n JOIN cards c ON c.nid = n.id
+			WHERE n.mid = ? AND n.csum = ? AND n.sfld = ? AND c.did = ? LIMIT 1`,
+			modelID, fieldChecksum(sortField), sortField, deckID,
+		).Scan(&noteID, &existingGUID, &flds, &tags)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Cards removed upstream only tag the note they left behind
+		if isRemovedFlashcard(flashcard) {
+			return noteUnchanged, nil
+		}
+		return noteAdded, c.addNote(modelID, deckID, flashcard)
+	}
+	if err != nil {
+		return noteUnchanged, err
+	}
+
+	mergedTags := mergeNoteTags(tags, flashcard.Tags)
+	if isRemovedFlashcard(flashcard) {
+		// Keep the fields of a note removed upstream, which only carry the previous run's text, and just tag it
+		if mergedTags == tags {
+			return noteUnchanged, nil
+		}
+		_, err = c.db.Exec("UPDATE notes SET tags = ?, mod = ?, usn = ? WHERE id = ?", mergedTags, c.now.Unix(), c.usn, noteID)
+		if err != nil {
+			return noteUnchanged, fmt.Errorf("updating note %q: %w", flashcard.Question, err)
+		}
+		return noteUpdated, nil
+	}
+
+	fields := strings.Join([]string{question, ankiFieldHTML(flashcard.Answer)}, ankiFieldSeparator)
+	if fields == flds && guid == existingGUID && mergedTags == tags {
+		return noteUnchanged, nil
+	}
+	_, err = c.db.Exec(
+		"UPDATE notes SET guid = ?, flds = ?, sfld = ?, csum = ?, tags = ?, mod = ?, usn = ? WHERE id = ?",
+		guid, fields, sortField, fieldChecksum(sortField), mergedTags, c.now.Unix(), c.usn, noteID,
+	)
+	if err != nil {
+		return noteUnchanged, fmt.Errorf("updating note %q: %w", flashcard.Question, err)
+	}
+	return noteUpdated, nil
+}
+
+// addFlashcards upserts every flashcard into the deck called deckName
+func (c *ankiCollection) addFlashcards(deckName string, flashcards []Flashcard) (collectionReport, error) {
+	var report collectionReport
+
+	deckID, err := c.ensureDeck(deckName)
+	if err != nil {
+		return report, err
+	}
+	modelID, err := c.ensureBasicModel(deckID)
+	if err != nil {
+		return report, err
+	}
+
+	for _, flashcard := range flashcards {
+		change, err := c.upsertNote(modelID, deckID, flashcard)
+		if err != nil {
+			return report, err
+		}
+		switch change {
+		case noteAdded:
+			report.Added++
+		case noteUpdated:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+	}
+
+	return report, nil
+}
+
+// loadColJSON decodes one of the JSON columns of the col table into a map keyed by id
+func (c *ankiCollection) loadColJSON(column string) (map[string]map[string]any, error) {
+	var raw string
+	if err := c.db.QueryRow("SELECT " + column + " FROM col").Scan(&raw); err != nil { // #nosec G202 -- column is one of a fixed set of names
+		return nil, fmt.Errorf("reading col.%s: %w", column, err)
+	}
+	values := map[string]map[string]any{}
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf("decoding col.%s: %w", column, err)
+	}
+	return values, nil
+}
+
+// saveColJSON encodes values back into one of the JSON columns of the col table
+func (c *ankiCollection) saveColJSON(column string, values map[string]map[string]any) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec("UPDATE col SET "+column+" = ?, mod = ?", string(data), c.now.UnixMilli()) // #nosec G202 -- column is one of a fixed set of names
+	if err != nil {
+		return fmt.Errorf("writing col.%s: %w", column, err)
+	}
+	return nil
+}
+
+// newAnkiDeck returns the JSON representation of a regular (non-filtered) deck
+func newAnkiDeck(id int64, name string, now time.Time) map[string]any {
+	return map[string]any{
+		"id":               id,
+		"name":             name,
+		"mod":              now.Unix(),
+		"usn":              -1,
+		"desc":             "",
+		"dyn":              0,
+		"conf":             1,
+		"collapsed":        false,
+		"browserCollapsed": false,
+		"extendNew":        10,
+		"extendRev":        50,
+		"newToday":         []int{0, 0},
+		"revToday":         []int{0, 0},
+		"lrnToday":         []int{0, 0},
+		"timeToday":        []int{0, 0},
+	}
+}
+
+// newBasicModel returns the JSON representation of a two-field Front/Back note type
+func newBasicModel(deckID int64, now time.Time) map[string]any {
+	field := func(name string, ord int) map[string]any {
+		return map[string]any{
+			"name": name, "ord": ord, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []any{},
+		}
+	}
+	return map[string]any{
+		"id":    basicModelID,
+		"name":  basicModelName,
+		"type":  0,
+		"mod":   now.Unix(),
+		"usn":   -1,
+		"sortf": 0,
+		"did":   deckID,
+		"flds":  []any{field("Front", 0), field("Back", 1)},
+		"tmpls": []any{map[string]any{
+			"name":  "Card 1",
+			"ord":   0,
+			"qfmt":  basicModelQuestionFormat,
+			"afmt":  basicModelAnswerFormat,
+			"did":   nil,
+			"bqfmt": "",
+			"bafmt": "",
+		}},
+		"css":       basicModelCSS,
+		"latexPre":  basicModelLatexPre,
+		"latexPost": basicModelLatexPost,
+		"latexsvg":  false,
+		"req":       []any{[]any{0, "any", []int{0}}},
+		"tags":      []any{},
+		"vers":      []any{},
+	}
+}
+
+// defaultAnkiDeckConfig returns Anki's stock "Default" deck options group
+func defaultAnkiDeckConfig() map[string]any {
+	return map[string]any{
+		"id":       1,
+		"name":     "Default",
+		"mod":      0,
+		"usn":      0,
+		"maxTaken": 60,
+		"autoplay": true,
+		"timer":    0,
+		"replayq":  true,
+		"dyn":      false,
+		"new": map[string]any{
+			"delays": []float64{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+			"order": 1, "perDay": 20, "bury": true, "separate": true,
+		},
+		"rev": map[string]any{
+			"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500,
+			"ivlFct": 1, "bury": true, "minSpace": 1,
+		},
+		"lapse": map[string]any{
+			"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+	}
+}
+
+// noteTags formats tags the way Anki stores them in notes.tags: space separated and space padded
+func noteTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " " + strings.Join(tags, " ") + " "
+}
+
+// mergeNoteTags adds tags to a note's existing notes.tags value, keeping tags the user added in Anki.
+// Review tags the flashcard no longer carries are dropped, so a card is only marked changed until the next run.
+func mergeNoteTags(existing string, tags []string) string {
+	merged := slices.DeleteFunc(strings.Fields(existing), func(tag string) bool {
+		return slices.Contains(reviewTags, tag) && !slices.Contains(tags, tag)
+	})
+	for _, tag := range tags {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return noteTags(merged)
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML removes HTML tags the way Anki does before computing sort fields and checksums
+func stripHTML(s string) string {
+	return strings.TrimSpace(htmlTagPattern.ReplaceAllString(s, ""))
+}
+
+// ankiFieldHTML escapes scraped text for an Anki field, which Anki renders as HTML, keeping its line breaks
+func ankiFieldHTML(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+// ankiSortField returns the plain text Anki stores in notes.sfld for a field: tags stripped, entities decoded
+func ankiSortField(field string) string {
+	return html.UnescapeString(stripHTML(field))
+}
+
+// protoString encodes a length-delimited protobuf field, as used in the configs of modern collections
+func protoString(field int, s string) []byte {
+	b := binary.AppendUvarint(nil, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// protoVarint encodes a varint protobuf field
+func protoVarint(field int, v uint64) []byte {
+	b := binary.AppendUvarint(nil, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// fieldChecksum returns Anki's duplicate-detection checksum: the first 32 bits of the field's SHA-1
+func fieldChecksum(s string) int64 {
+	sum := sha1.Sum([]byte(s)) // #nosec G401 -- not used for security
+	v, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return v
+}
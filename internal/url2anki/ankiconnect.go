@@ -0,0 +1,350 @@
+package url2anki
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ankiConnectVersion is the AnkiConnect API version url2anki speaks
+	ankiConnectVersion = 6
+	// ankiConnectModelName is the stock note type AnkiConnect notes are created with
+	ankiConnectModelName = "Basic"
+	// ankiConnectGUIDTagPrefix prefixes the tag carrying a note's GUID, since AnkiConnect cannot set GUIDs directly
+	ankiConnectGUIDTagPrefix = "url2anki::guid::"
+)
+
+// ankiConnectClient talks to the AnkiConnect add-on running inside desktop Anki
+type ankiConnectClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// ankiConnectNote is a note as accepted by AnkiConnect's addNotes and canAddNotes actions
+type ankiConnectNote struct {
+	DeckName  string            `json:"deckName"`
+	ModelName string            `json:"modelName"`
+	Fields    map[string]string `json:"fields"`
+	Options   map[string]any    `json:"options,omitempty"`
+	Tags      []string          `json:"tags"`
+}
+
+// ankiConnectNoteInfo is a note as returned by AnkiConnect's notesInfo action
+type ankiConnectNoteInfo struct {
+	NoteID int64    `json:"noteId"`
+	Tags   []string `json:"tags"`
+	Fields map[string]struct {
+		Value string `json:"value"`
+	} `json:"fields"`
+}
+
+// ankiConnectReport summarises the outcome of pushing flashcards to AnkiConnect
+type ankiConnectReport struct {
+	Added   int
+	Updated int
+	// Existing holds the flashcards matched to a note of an earlier run by their GUID tag
+	Existing []Flashcard
+	// Duplicates holds the flashcards Anki rejected as duplicates of a note it already has
+	Duplicates []Flashcard
+	// Failed holds the flashcards addNotes accepted but did not create a note for
+	Failed []Flashcard
+}
+
+// newAnkiConnectClient returns a client for the AnkiConnect endpoint (EX: http://127.0.0.1:8765)
+func newAnkiConnectClient(endpoint string) *ankiConnectClient {
+	return &ankiConnectClient{
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// invoke calls an AnkiConnect action and decodes its result into result.
+// Params are left out when nil, since AnkiConnect rejects a null params object.
+func (c *ankiConnectClient) invoke(action string, params any, result any) error {
+	request := map[string]any{
+		"action":  action,
+		"version": ankiConnectVersion,
+	}
+	if params != nil {
+		request["params"] = params
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.httpClient.Post(c.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("AnkiConnect %s: unexpected status %s", action, res.Status)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *string         `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("AnkiConnect %s: %w", action, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("AnkiConnect %s: %s", action, *response.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// pushToAnkiConnect adds the request's flashcards to its deck, creating the deck if needed.
+// Flashcards whose GUID tag is already on a note, and notes Anki rejects as duplicates, are reported
+// apart and updated when updateExisting is set and their fields changed.
+func pushToAnkiConnect(client *ankiConnectClient, request AnkiSyncRequest, updateExisting bool) (ankiConnectReport, error) {
+	var report ankiConnectReport
+
+	// Create the deck if it is missing
+	var deckNames []string
+	if err := client.invoke("deckNames", nil, &deckNames); err != nil {
+		return report, err
+	}
+	deckExists := false
+	for _, name := range deckNames {
+		if name == request.DeckName {
+			deckExists = true
+			break
+		}
+	}
+	if !deckExists {
+		if err := client.invoke("createDeck", map[string]string{"deck": request.DeckName}, nil); err != nil {
+			return report, err
+		}
+	}
+
+	// Flashcards added by an earlier run carry their GUID as a tag, even after their question was edited
+	known, err := findAnkiConnectGUIDs(client, request.Flashcards)
+	if err != nil {
+		return report, err
+	}
+	// Cards removed upstream only tag the notes they left behind and are never added again
+	var flashcards, removed []Flashcard
+	for _, flashcard := range request.Flashcards {
+		switch {
+		case isRemovedFlashcard(flashcard):
+			removed = append(removed, flashcard)
+		case flashcard.GUID != "" && known[flashcard.GUID]:
+			report.Existing = append(report.Existing, flashcard)
+		default:
+			flashcards = append(flashcards, flashcard)
+		}
+	}
+	if len(flashcards) == 0 {
+		return report, updateExistingNotes(client, request.DeckName, &report, removed, updateExisting)
+	}
+
+	// Ask Anki which notes it would accept, so duplicates can be told apart from real failures
+	notes := make([]ankiConnectNote, len(flashcards))
+	for i, flashcard := range flashcards {
+		notes[i] = ankiConnectNote{
+			DeckName:  request.DeckName,
+			ModelName: ankiConnectModelName,
+			Fields:    ankiConnectFields(flashcard),
+			Options:   map[string]any{"allowDuplicate": false, "duplicateScope": "deck"},
+			Tags:      ankiConnectTags(flashcard),
+		}
+	}
+	var canAdd []bool
+	if err := client.invoke("canAddNotes", map[string]any{"notes": notes}, &canAdd); err != nil {
+		return report, err
+	}
+	if len(canAdd) != len(notes) {
+		return report, errors.New("AnkiConnect canAddNotes returned an unexpected number of results")
+	}
+
+	var toAdd []ankiConnectNote
+	var added []Flashcard
+	for i, ok := range canAdd {
+		if ok {
+			toAdd = append(toAdd, notes[i])
+			added = append(added, flashcards[i])
+		} else {
+			report.Duplicates = append(report.Duplicates, flashcards[i])
+		}
+	}
+
+	if len(toAdd) > 0 {
+		var ids []*int64
+		if err := client.invoke("addNotes", map[string]any{"notes": toAdd}, &ids); err != nil {
+			return report, err
+		}
+		if len(ids) != len(toAdd) {
+			return report, errors.New("AnkiConnect addNotes returned an unexpected number of results")
+		}
+		for i, id := range ids {
+			if id != nil {
+				report.Added++
+			} else {
+				report.Failed = append(report.Failed, added[i])
+			}
+		}
+	}
+
+	return report, updateExistingNotes(client, request.DeckName, &report, removed, updateExisting)
+}
+
+// updateExistingNotes brings the review tags of the notes behind the report's existing and duplicate
+// flashcards and the removed cards up to date, and updates their fields in deckName when updateExisting is set
+func updateExistingNotes(client *ankiConnectClient, deckName string, report *ankiConnectReport, removed []Flashcard, updateExisting bool) error {
+	existing := slices.Concat(report.Existing, report.Duplicates, removed)
+	if len(existing) == 0 {
+		return nil
+	}
+	updated, err := updateAnkiConnectNotes(client, deckName, existing, updateExisting)
+	report.Updated = updated
+	return err
+}
+
+// findAnkiConnectGUIDs returns the GUIDs of the flashcards that already have a note tagged with them
+func findAnkiConnectGUIDs(client *ankiConnectClient, flashcards []Flashcard) (map[string]bool, error) {
+	query := ankiConnectGUIDQuery(flashcards)
+	if query == "" {
+		return nil, nil
+	}
+	var noteIDs []int64
+	if err := client.invoke("findNotes", map[string]string{"query": query}, &noteIDs); err != nil {
+		return nil, err
+	}
+	if len(noteIDs) == 0 {
+		return nil, nil
+	}
+	var infos []ankiConnectNoteInfo
+	if err := client.invoke("notesInfo", map[string]any{"notes": noteIDs}, &infos); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, info := range infos {
+		for _, tag := range info.Tags {
+			if guid, ok := strings.CutPrefix(tag, ankiConnectGUIDTagPrefix); ok {
+				known[guid] = true
+			}
+		}
+	}
+	return known, nil
+}
+
+// ankiConnectGUIDQuery returns the Anki search matching the notes tagged with any of the flashcards' GUIDs
+func ankiConnectGUIDQuery(flashcards []Flashcard) string {
+	var terms []string
+	for _, flashcard := range flashcards {
+		if flashcard.GUID != "" {
+			terms = append(terms, fmt.Sprintf(`"tag:%s"`, escapeAnkiSearch(ankiConnectGUIDTagPrefix+flashcard.GUID)))
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// updateAnkiConnectNotes adds and removes the review tags of the notes matching the flashcards, and rewrites
+// notes whose fields differ from the flashcard's when updateFields is set. Notes are matched by GUID tag first
+// and by question in deckName otherwise.
+func updateAnkiConnectNotes(client *ankiConnectClient, deckName string, flashcards []Flashcard, updateFields bool) (int, error) {
+	query := fmt.Sprintf(`"deck:%s"`, escapeAnkiSearch(deckName))
+	if guids := ankiConnectGUIDQuery(flashcards); guids != "" {
+		query += " OR " + guids
+	}
+	var noteIDs []int64
+	if err := client.invoke("findNotes", map[string]string{"query": query}, &noteIDs); err != nil {
+		return 0, err
+	}
+	var infos []ankiConnectNoteInfo
+	if err := client.invoke("notesInfo", map[string]any{"notes": noteIDs}, &infos); err != nil {
+		return 0, err
+	}
+
+	byGUID := map[string]ankiConnectNoteInfo{}
+	byQuestion := map[string]ankiConnectNoteInfo{}
+	for _, info := range infos {
+		byQuestion[info.Fields["Front"].Value] = info
+		for _, tag := range info.Tags {
+			if strings.HasPrefix(tag, ankiConnectGUIDTagPrefix) {
+				byGUID[strings.TrimPrefix(tag, ankiConnectGUIDTagPrefix)] = info
+			}
+		}
+	}
+
+	updated := 0
+	addTags := map[string][]int64{}
+	removeTags := map[string][]int64{}
+	for _, flashcard := range flashcards {
+		info, ok := byGUID[flashcard.GUID]
+		if !ok || flashcard.GUID == "" {
+			info, ok = byQuestion[ankiFieldHTML(flashcard.Question)]
+		}
+		if !ok {
+			continue
+		}
+		for _, tag := range reviewTags {
+			switch hasTag, wantTag := slices.Contains(info.Tags, tag), slices.Contains(flashcard.Tags, tag); {
+			case wantTag && !hasTag:
+				addTags[tag] = append(addTags[tag], info.NoteID)
+			case hasTag && !wantTag:
+				removeTags[tag] = append(removeTags[tag], info.NoteID)
+			}
+		}
+
+		// Removed cards carry the fields of the previous run, so they never overwrite the note
+		fields := ankiConnectFields(flashcard)
+		if !updateFields || isRemovedFlashcard(flashcard) || (info.Fields["Front"].Value == fields["Front"] && info.Fields["Back"].Value == fields["Back"]) {
+			continue
+		}
+		params := map[string]any{"note": map[string]any{
+			"id":     info.NoteID,
+			"fields": fields,
+		}}
+		if err := client.invoke("updateNoteFields", params, nil); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	for _, tag := range reviewTags {
+		if notes := addTags[tag]; len(notes) > 0 {
+			if err := client.invoke("addTags", map[string]any{"notes": notes, "tags": tag}, nil); err != nil {
+				return updated, err
+			}
+		}
+		if notes := removeTags[tag]; len(notes) > 0 {
+			if err := client.invoke("removeTags", map[string]any{"notes": notes, "tags": tag}, nil); err != nil {
+				return updated, err
+			}
+		}
+	}
+
+	return updated, nil
+}
+
+// ankiConnectFields returns the Front and Back fields of a flashcard's note, escaped as Anki HTML
+func ankiConnectFields(flashcard Flashcard) map[string]string {
+	return map[string]string{"Front": ankiFieldHTML(flashcard.Question), "Back": ankiFieldHTML(flashcard.Answer)}
+}
+
+// ankiConnectTags returns the tags a flashcard's note is created with
+func ankiConnectTags(flashcard Flashcard) []string {
+	tags := append([]string{}, flashcard.Tags...)
+	if flashcard.GUID != "" {
+		tags = append(tags, ankiConnectGUIDTagPrefix+flashcard.GUID)
+	}
+	return tags
+}
+
+// escapeAnkiSearch escapes the characters Anki's search syntax treats specially inside a quoted term
+func escapeAnkiSearch(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `*`, `\*`, `_`, `\_`).Replace(s)
+}
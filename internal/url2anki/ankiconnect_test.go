@@ -0,0 +1,366 @@
+package url2anki
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAnkiConnect is an in-memory stand-in for the AnkiConnect add-on
+type fakeAnkiConnect struct {
+	mu      sync.Mutex
+	decks   map[string]bool
+	notes   map[int64]ankiConnectNote
+	nextID  int64
+	actions []string
+	// failAdds makes addNotes return a null id for every note
+	failAdds bool
+}
+
+// newFakeAnkiConnect returns a fake AnkiConnect server holding the given decks and notes
+func newFakeAnkiConnect(t *testing.T, decks []string, notes []ankiConnectNote) (*fakeAnkiConnect, *httptest.Server) {
+	fake := &fakeAnkiConnect{decks: map[string]bool{}, notes: map[int64]ankiConnectNote{}, nextID: 1}
+	for _, deck := range decks {
+		fake.decks[deck] = true
+	}
+	for _, note := range notes {
+		fake.notes[fake.nextID] = note
+		fake.nextID++
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+// serveHTTP dispatches a single AnkiConnect action
+func (f *fakeAnkiConnect) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var request struct {
+		Action  string          `json:"action"`
+		Version int             `json:"version"`
+		Params  json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Like AnkiConnect, only accept params that are absent or an object
+	if params := bytes.TrimSpace(request.Params); len(params) > 0 && params[0] != '{' {
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": nil, "error": "params must be an object"})
+		return
+	}
+	f.actions = append(f.actions, request.Action)
+
+	var result any
+	var errMsg *string
+	switch request.Action {
+	case "deckNames":
+		names := []string{}
+		for name := range f.decks {
+			names = append(names, name)
+		}
+		result = names
+	case "createDeck":
+		var params struct{ Deck string }
+		_ = json.Unmarshal(request.Params, &params)
+		f.decks[params.Deck] = true
+		result = 1
+	case "canAddNotes":
+		var params struct{ Notes []ankiConnectNote }
+		_ = json.Unmarshal(request.Params, &params)
+		canAdd := make([]bool, len(params.Notes))
+		for i, note := range params.Notes {
+			canAdd[i] = f.findNote(note.DeckName, note.Fields["Front"]) == 0
+		}
+		result = canAdd
+	case "addNotes":
+		var params struct{ Notes []ankiConnectNote }
+		_ = json.Unmarshal(request.Params, &params)
+		ids := make([]any, len(params.Notes))
+		for i, note := range params.Notes {
+			if f.failAdds || !f.decks[note.DeckName] {
+				continue
+			}
+			f.notes[f.nextID] = note
+			ids[i] = f.nextID
+			f.nextID++
+		}
+		result = ids
+	case "findNotes":
+		var params struct{ Query string }
+		_ = json.Unmarshal(request.Params, &params)
+		ids := []int64{}
+		for id, note := range f.notes {
+			if f.matches(note, params.Query) {
+				ids = append(ids, id)
+			}
+		}
+		result = ids
+	case "notesInfo":
+		var params struct{ Notes []int64 }
+		_ = json.Unmarshal(request.Params, &params)
+		infos := []map[string]any{}
+		for _, id := range params.Notes {
+			fields := map[string]any{}
+			for name, value := range f.notes[id].Fields {
+				fields[name] = map[string]any{"value": value, "order": 0}
+			}
+			tags := append([]string{}, f.notes[id].Tags...)
+			infos = append(infos, map[string]any{"noteId": id, "tags": tags, "fields": fields})
+		}
+		result = infos
+	case "updateNoteFields":
+		var params struct {
+			Note struct {
+				ID     int64             `json:"id"`
+				Fields map[string]string `json:"fields"`
+			}
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		note := f.notes[params.Note.ID]
+		for name, value := range params.Note.Fields {
+			note.Fields[name] = value
+		}
+		f.notes[params.Note.ID] = note
+	case "addTags", "removeTags":
+		var params struct {
+			Notes []int64
+			Tags  string
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		for _, id := range params.Notes {
+			note := f.notes[id]
+			note.Tags = slices.DeleteFunc(slices.Clone(note.Tags), func(tag string) bool { return tag == params.Tags })
+			if request.Action == "addTags" {
+				note.Tags = append(note.Tags, params.Tags)
+			}
+			f.notes[id] = note
+		}
+	default:
+		msg := "unsupported action"
+		errMsg = &msg
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": errMsg})
+}
+
+// matches reports whether note matches query, an OR of quoted "deck:" and "tag:" terms
+func (f *fakeAnkiConnect) matches(note ankiConnectNote, query string) bool {
+	for _, term := range strings.Split(query, " OR ") {
+		term = strings.ReplaceAll(strings.Trim(term, `"`), `\_`, "_")
+		if deck, ok := strings.CutPrefix(term, "deck:"); ok && note.DeckName == deck {
+			return true
+		}
+		if tag, ok := strings.CutPrefix(term, "tag:"); ok && slices.Contains(note.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// findNote returns the id of the note in deck whose Front field is front, or 0
+func (f *fakeAnkiConnect) findNote(deck, front string) int64 {
+	for id, note := range f.notes {
+		if note.DeckName == deck && note.Fields["Front"] == front {
+			return id
+		}
+	}
+	return 0
+}
+
+// TestPushToAnkiConnectCreatesDeck tests that pushToAnkiConnect creates a missing deck and adds every note
+func TestPushToAnkiConnectCreatesDeck(t *testing.T) {
+	fake, server := newFakeAnkiConnect(t, []string{"Default"}, nil)
+
+	request := AnkiSyncRequest{
+		DeckName: "Glossary",
+		Flashcards: []Flashcard{
+			{Question: "Question 1", Answer: "Answer 1"},
+			{Question: "Question 2", Answer: "Answer 2"},
+		},
+	}
+	report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, false)
+	if err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+
+	if !fake.decks["Glossary"] {
+		t.Errorf("Expected deck Glossary to be created")
+	}
+	if report.Added != 2 || len(report.Duplicates) != 0 {
+		t.Errorf("Expected 2 added and no duplicates, got %+v", report)
+	}
+	if len(fake.notes) != 2 {
+		t.Errorf("Expected 2 notes in Anki, got %d", len(fake.notes))
+	}
+}
+
+// TestPushToAnkiConnectDuplicates tests that duplicates are reported and only updated when requested
+func TestPushToAnkiConnectDuplicates(t *testing.T) {
+	existing := []ankiConnectNote{
+		{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 1", "Back": "Old answer"}},
+		{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 2", "Back": "Answer 2"}},
+	}
+	request := AnkiSyncRequest{
+		DeckName: "Glossary",
+		Flashcards: []Flashcard{
+			{Question: "Question 1", Answer: "Answer 1"},
+			{Question: "Question 2", Answer: "Answer 2"},
+			{Question: "Question 3", Answer: "Answer 3"},
+		},
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, existing)
+
+		report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, false)
+		if err != nil {
+			t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+		}
+		if report.Added != 1 || report.Updated != 0 || len(report.Duplicates) != 2 {
+			t.Errorf("Expected 1 added, 0 updated and 2 duplicates, got %+v", report)
+		}
+		for _, action := range fake.actions {
+			if action == "createDeck" || action == "updateNoteFields" {
+				t.Errorf("Unexpected %s call", action)
+			}
+		}
+	})
+
+	t.Run("update existing", func(t *testing.T) {
+		fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, []ankiConnectNote{
+			{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 1", "Back": "Old answer"}},
+			{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 2", "Back": "Answer 2"}},
+		})
+
+		report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, true)
+		if err != nil {
+			t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+		}
+		if report.Added != 1 || report.Updated != 1 {
+			t.Errorf("Expected 1 added and 1 updated, got %+v", report)
+		}
+		if back := fake.notes[fake.findNote("Glossary", "Question 1")].Fields["Back"]; back != "Answer 1" {
+			t.Errorf("Expected Question 1 to be updated to %q, got %q", "Answer 1", back)
+		}
+	})
+}
+
+// TestAnkiConnectError tests that errors reported by AnkiConnect are surfaced
+func TestAnkiConnectError(t *testing.T) {
+	_, server := newFakeAnkiConnect(t, nil, nil)
+
+	err := newAnkiConnectClient(server.URL).invoke("sync", nil, nil)
+	if err == nil {
+		t.Fatalf("Expected an error for an unsupported action")
+	}
+}
+
+// TestPushToAnkiConnectEditedQuestion tests that a card whose question was edited since the last run is
+// found by its GUID tag and updated instead of being added again
+func TestPushToAnkiConnectEditedQuestion(t *testing.T) {
+	fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, []ankiConnectNote{{
+		DeckName: "Glossary",
+		Fields:   map[string]string{"Front": "Old question", "Back": "Answer 1"},
+		Tags:     []string{ankiConnectGUIDTagPrefix + "abc"},
+	}})
+	request := AnkiSyncRequest{
+		DeckName:   "Glossary",
+		Flashcards: []Flashcard{{Question: "New question", Answer: "Answer 1", GUID: "abc"}},
+	}
+
+	report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, true)
+	if err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+	if report.Added != 0 || report.Updated != 1 || len(report.Existing) != 1 || len(report.Duplicates) != 0 {
+		t.Errorf("Expected the note to be matched and updated rather than added, got %+v", report)
+	}
+	if len(fake.notes) != 1 || fake.notes[1].Fields["Front"] != "New question" {
+		t.Errorf("Expected the existing note's question to be rewritten, got %+v", fake.notes)
+	}
+}
+
+// TestPushToAnkiConnectFailed tests that notes addNotes did not create are reported as failures
+func TestPushToAnkiConnectFailed(t *testing.T) {
+	fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, nil)
+	request := AnkiSyncRequest{
+		DeckName:   "Glossary",
+		Flashcards: []Flashcard{{Question: "Question 1", Answer: "Answer 1"}},
+	}
+	fake.failAdds = true
+
+	report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, false)
+	if err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+	if report.Added != 0 || len(report.Failed) != 1 || report.Failed[0].Question != "Question 1" {
+		t.Errorf("Expected Question 1 to be reported as failed, got %+v", report)
+	}
+}
+
+// TestPushToAnkiConnectEscapesHTML tests that fields are sent as escaped HTML and compared that way
+func TestPushToAnkiConnectEscapesHTML(t *testing.T) {
+	fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, nil)
+	request := AnkiSyncRequest{
+		DeckName:   "Glossary",
+		Flashcards: []Flashcard{{Question: "kubectl logs <pod-name>", Answer: "a < b\nb > c"}},
+	}
+
+	if _, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, true); err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+	note := fake.notes[fake.findNote("Glossary", "kubectl logs &lt;pod-name&gt;")]
+	if back := note.Fields["Back"]; back != "a &lt; b<br>b &gt; c" {
+		t.Errorf("Expected the answer to be escaped, got %q", back)
+	}
+
+	// Pushing the same card again should find the note by its escaped question and leave it alone
+	report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, true)
+	if err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+	if report.Added != 0 || report.Updated != 0 || len(report.Duplicates) != 1 {
+		t.Errorf("Expected the note to be an unchanged duplicate, got %+v", report)
+	}
+}
+
+// TestPushToAnkiConnectReviewTags tests that removed cards only tag their existing notes and that stale
+// review tags are taken off notes whose card is unchanged again
+func TestPushToAnkiConnectReviewTags(t *testing.T) {
+	fake, server := newFakeAnkiConnect(t, []string{"Glossary"}, []ankiConnectNote{
+		{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 1", "Back": "Answer 1"}, Tags: []string{changedTag}},
+		{DeckName: "Glossary", Fields: map[string]string{"Front": "Question 2", "Back": "My own answer"}},
+	})
+	request := AnkiSyncRequest{
+		DeckName: "Glossary",
+		Flashcards: []Flashcard{
+			{Question: "Question 1", Answer: "Answer 1"},
+			{Question: "Question 2", Answer: "Answer 2", Tags: []string{removedTag}},
+			{Question: "Question 3", Answer: "Answer 3", Tags: []string{removedTag}},
+		},
+	}
+
+	report, err := pushToAnkiConnect(newAnkiConnectClient(server.URL), request, true)
+	if err != nil {
+		t.Fatalf("pushToAnkiConnect returned an error: %v", err)
+	}
+	if report.Added != 0 || report.Updated != 0 || len(fake.notes) != 2 {
+		t.Errorf("Expected nothing to be added or updated, got %+v and %d notes", report, len(fake.notes))
+	}
+	if tags := fake.notes[1].Tags; slices.Contains(tags, changedTag) {
+		t.Errorf("Expected the stale changed tag to be removed, got %v", tags)
+	}
+	note := fake.notes[2]
+	if !slices.Contains(note.Tags, removedTag) || note.Fields["Back"] != "My own answer" {
+		t.Errorf("Expected the removed card's note to be tagged and left alone, got %+v", note)
+	}
+}
@@ -0,0 +1,93 @@
+package url2anki
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// exportFlashcardsToAPKGFile exports the flashcards to an Anki package (.apkg) containing a single deck
+func exportFlashcardsToAPKGFile(flashcards []Flashcard, deckName, filename string) error {
+	// Build the collection in a scratch directory, then zip it up alongside an empty media manifest
+	tmpDir, err := os.MkdirTemp("", "url2anki-apkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	collectionPath := filepath.Join(tmpDir, "collection.anki2")
+	if err := writeDeckCollection(flashcards, deckName, collectionPath); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename) //#nosec G304
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	if err := addFileToZip(archive, "collection.anki2", collectionPath); err != nil {
+		return err
+	}
+
+	// The media manifest maps numbered zip entries to file names; url2anki never bundles media
+	media, err := archive.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := media.Write([]byte("{}")); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// writeDeckCollection writes a new collection at path holding the flashcards in a deck called deckName
+func writeDeckCollection(flashcards []Flashcard, deckName, path string) error {
+	collection, err := newAnkiCollection(path)
+	if err != nil {
+		return err
+	}
+	if err := addDeckNotes(collection, flashcards, deckName); err != nil {
+		collection.Close()
+		return err
+	}
+	return collection.Close()
+}
+
+// addDeckNotes adds every flashcard as a new note in a deck called deckName
+func addDeckNotes(collection *ankiCollection, flashcards []Flashcard, deckName string) error {
+	deckID, err := collection.ensureDeck(deckName)
+	if err != nil {
+		return err
+	}
+	modelID, err := collection.ensureBasicModel(deckID)
+	if err != nil {
+		return err
+	}
+
+	for _, flashcard := range flashcards {
+		if err := collection.addNote(modelID, deckID, flashcard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFileToZip copies the file at path into the archive under name
+func addFileToZip(archive *zip.Writer, name, path string) error {
+	src, err := os.Open(path) //#nosec G304
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
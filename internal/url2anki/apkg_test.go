@@ -0,0 +1,111 @@
+package url2anki
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExportFlashcardsToAPKGFile tests the exportFlashcardsToAPKGFile function
+func TestExportFlashcardsToAPKGFile(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1"},
+		{Question: "Question 2", Answer: "Answer 2"},
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "deck.apkg")
+
+	// Call the exportFlashcardsToAPKGFile function
+	if err := exportFlashcardsToAPKGFile(flashcards, "Glossary", filename); err != nil {
+		t.Fatalf("exportFlashcardsToAPKGFile returned an error: %v", err)
+	}
+
+	// Unpack the package and verify it has a collection and a media manifest
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("Failed to open the package: %v", err)
+	}
+	defer archive.Close()
+
+	entries := map[string][]byte{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		entries[f.Name] = data
+	}
+
+	if string(entries["media"]) != "{}" {
+		t.Errorf("Expected empty media manifest, got %q", entries["media"])
+	}
+
+	collectionPath := filepath.Join(dir, "collection.anki2")
+	if err := os.WriteFile(collectionPath, entries["collection.anki2"], 0600); err != nil {
+		t.Fatalf("Failed to write the collection: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", collectionPath)
+	if err != nil {
+		t.Fatalf("Failed to open the collection: %v", err)
+	}
+	defer db.Close()
+
+	// The deck should exist by name and hold one card per flashcard
+	var decksJSON string
+	if err := db.QueryRow("SELECT decks FROM col").Scan(&decksJSON); err != nil {
+		t.Fatalf("Failed to read decks: %v", err)
+	}
+	var decks map[string]struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		t.Fatalf("Failed to decode decks: %v", err)
+	}
+	var deckID int64
+	for _, deck := range decks {
+		if deck.Name == "Glossary" {
+			deckID = deck.ID
+		}
+	}
+	if deckID == 0 {
+		t.Fatalf("Expected a deck called Glossary, got %s", decksJSON)
+	}
+
+	rows, err := db.Query("SELECT n.flds FROM notes n JOIN cards c ON c.nid = n.id WHERE c.did = ? ORDER BY c.due", deckID)
+	if err != nil {
+		t.Fatalf("Failed to query notes: %v", err)
+	}
+	defer rows.Close()
+
+	var notes []string
+	for rows.Next() {
+		var flds string
+		if err := rows.Scan(&flds); err != nil {
+			t.Fatalf("Failed to scan note: %v", err)
+		}
+		notes = append(notes, flds)
+	}
+
+	if len(notes) != len(flashcards) {
+		t.Fatalf("Expected %d notes, got %d", len(flashcards), len(notes))
+	}
+	for i, flds := range notes {
+		expected := strings.Join([]string{flashcards[i].Question, flashcards[i].Answer}, ankiFieldSeparator)
+		if flds != expected {
+			t.Errorf("Expected note fields %q, got %q", expected, flds)
+		}
+	}
+}
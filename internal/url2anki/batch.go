@@ -0,0 +1,269 @@
+package url2anki
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/cobra"
+)
+
+// sourceTagPrefix starts the tag naming the page each card of a multi-page scrape came from
+const sourceTagPrefix = "source::"
+
+// pageScrape is the flashcards scraped from a single page, with their GUIDs already assigned
+type pageScrape struct {
+	URL        string        `json:"url"`
+	Title      string        `json:"title"`
+	Flashcards []Flashcard   `json:"flashcards"`
+	Skipped    []skippedItem `json:"-"`
+}
+
+// sourceURLs collects the pages to scrape from --start (on the crawl command), --url, the positional
+// arguments and --url-file, in that order and without duplicates. It may only come back empty when
+// the pages are listed in a --sitemap.
+func sourceURLs(cmd *cobra.Command, args []string) ([]string, error) {
+	starts, _ := cmd.Flags().GetStringArray("start")
+	urls, _ := cmd.Flags().GetStringArray("url")
+	urlFile, _ := cmd.Flags().GetString("url-file")
+	urls = append(append(append([]string{}, starts...), urls...), args...)
+	if urlFile != "" {
+		listed, err := readURLFile(urlFile)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, listed...)
+	}
+
+	var pages []string
+	seen := map[string]bool{}
+	for _, raw := range urls {
+		pageURL, err := parsePageURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[pageURL] {
+			seen[pageURL] = true
+			pages = append(pages, pageURL)
+		}
+	}
+	if sitemap, _ := cmd.Flags().GetString("sitemap"); sitemap != "" {
+		if _, err := parsePageURL(sitemap); err != nil {
+			return nil, fmt.Errorf("invalid --sitemap: %w", err)
+		}
+	} else if len(pages) == 0 {
+		return nil, errors.New("no URL to scrape: pass --url, --url-file, --sitemap or URLs as arguments")
+	}
+	return pages, nil
+}
+
+// readURLFile returns the URLs listed in filename, one per line, ignoring blank lines and # comments
+func readURLFile(filename string) ([]string, error) {
+	f, err := os.Open(filename) // #nosec G304 -- filename from user CLI arg, expected
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	return urls, nil
+}
+
+// parsePageURL checks that raw is an absolute http(s) URL and returns it in canonical form
+func parsePageURL(raw string) (string, error) {
+	pageURL, err := url.ParseRequestURI(strings.TrimSpace(raw))
+	if err != nil || pageURL.Host == "" || (pageURL.Scheme != "http" && pageURL.Scheme != "https") {
+		return "", fmt.Errorf("invalid URL %q: expected an absolute http or https URL", raw)
+	}
+	return pageURL.String(), nil
+}
+
+// scrapePage fetches the page at pageURL and extracts its flashcards with the options
+func scrapePage(pageURL string, options extractOptions) (pageScrape, error) {
+	doc, err := fetchDocument(pageURL)
+	if err != nil {
+		return pageScrape{}, err
+	}
+	return scrapeDocument(context.Background(), pageURL, doc, options)
+}
+
+// scrapeDocument extracts the flashcards of the already fetched page at pageURL with the options,
+// giving up on detail pages when ctx is done
+func scrapeDocument(ctx context.Context, pageURL string, doc *goquery.Document, options extractOptions) (pageScrape, error) {
+	var scraped extraction
+	var err error
+	if options.LinkSelector != "" {
+		// The answers live on the pages the index links to
+		scraped, err = extractLinkedPages(ctx, doc, pageURL, options)
+	} else {
+		scraped, err = extract(doc, options)
+	}
+	if err != nil {
+		return pageScrape{}, err
+	}
+	// Give every card a stable GUID so re-scrapes update existing notes instead of duplicating them
+	assignGUIDs(scraped.Flashcards, pageURL, scraped.Keys)
+
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return pageScrape{}, err
+	}
+	return pageScrape{
+		URL:        pageURL,
+		Title:      pageTitle(doc, parsed),
+		Flashcards: scraped.Flashcards,
+		Skipped:    scraped.Skipped,
+	}, nil
+}
+
+// scrapePages scrapes every URL with the same options. A page that fails is reported and left out
+// without losing the others. When there is more than one URL, each card is tagged with its source page.
+// Pages found in unchanged are not fetched again; the flashcards they produced last time are kept.
+func scrapePages(msg io.Writer, urls []string, options extractOptions, unchanged map[string]pageScrape) []pageScrape {
+	var pages []pageScrape
+	for _, pageURL := range urls {
+		if page, ok := unchanged[pageURL]; ok {
+			fmt.Fprintf(msg, "Kept %d flashcards from %s, unchanged since the previous run\n", len(page.Flashcards), pageURL)
+			pages = append(pages, page)
+			continue
+		}
+		page, err := scrapePage(pageURL, options)
+		if err != nil {
+			fmt.Fprintf(msg, "Error scraping %s: %v\n", pageURL, err)
+			continue
+		}
+		printSkippedItems(msg, page.Skipped)
+		if len(urls) > 1 {
+			page.tagSource()
+			fmt.Fprintf(msg, "Scraped %d flashcards from %s\n", len(page.Flashcards), pageURL)
+		}
+		pages = append(pages, page)
+	}
+	if len(urls) > 1 && len(pages) < len(urls) {
+		fmt.Fprintf(msg, "Scraped %d of %d pages\n", len(pages), len(urls))
+	}
+	return pages
+}
+
+// tagSource tags every card of the page with the page it came from
+func (p *pageScrape) tagSource() {
+	tag := sourceTag(p.URL)
+	for i := range p.Flashcards {
+		p.Flashcards[i] = withTag(p.Flashcards[i], tag)
+	}
+}
+
+// sourceTag returns the tag naming the page a card came from (EX: source::example.com/glossary/a)
+func sourceTag(pageURL string) string {
+	parsed, err := url.Parse(normalizeSourceURL(pageURL))
+	if err != nil {
+		return sourceTagPrefix + sanitizeTag(pageURL)
+	}
+	source := parsed.Host + parsed.Path
+	if parsed.RawQuery != "" {
+		source += "?" + parsed.RawQuery
+	}
+	return sourceTagPrefix + sanitizeTag(source)
+}
+
+// deliverPages merges the flashcards of the scraped pages into one deck, diffs each page against the
+// state file when one is given, and delivers the result to every output
+func deliverPages(cmd *cobra.Command, msg io.Writer, outputs []outputTarget, pages []pageScrape, options extractOptions) error {
+	preview, _ := cmd.Flags().GetBool("preview")
+	deckName, _ := cmd.Flags().GetString("deck")
+	stateFile, _ := cmd.Flags().GetString("state")
+	onlyChanges, _ := cmd.Flags().GetBool("only-changes")
+	tagChanges, _ := cmd.Flags().GetBool("tag-changes")
+
+	var flashcards []Flashcard
+	for _, page := range pages {
+		flashcards = append(flashcards, page.Flashcards...)
+	}
+
+	// Compare each page against the previous run of the same URL and selectors
+	var state *scrapeState
+	if stateFile != "" {
+		var err error
+		state, err = loadScrapeState(stateFile)
+		if err != nil {
+			return fmt.Errorf("loading state file: %w", err)
+		}
+		var diff scrapeDiff
+		for _, page := range pages {
+			pageDiff := diffFlashcards(state.Scrapes[pageStateKey(page.URL, options)].Flashcards, page.Flashcards)
+			diff.Added = append(diff.Added, pageDiff.Added...)
+			diff.Changed = append(diff.Changed, pageDiff.Changed...)
+			diff.Removed = append(diff.Removed, pageDiff.Removed...)
+			diff.Unchanged = append(diff.Unchanged, pageDiff.Unchanged...)
+		}
+		printScrapeDiff(msg, diff, msg == os.Stdout && useColor())
+		if onlyChanges {
+			flashcards = diff.deltaFlashcards(tagChanges)
+		} else if tagChanges {
+			flashcards = diff.tagFlashcards(flashcards)
+		}
+	}
+
+	// Name the deck after the first page unless one was given explicitly
+	if deckName == "" {
+		deckName = pages[0].Title
+	}
+
+	// If preview is enabled, display flashcards as a table and ask for confirmation
+	if preview && !confirmFlashcards(msg, flashcards) {
+		return errPreviewRejected
+	}
+
+	if err := deliverFlashcards(cmd, msg, outputs, flashcards, deckName); err != nil {
+		return err
+	}
+
+	// Remember this run's cards only once everything was exported, so a failed run is retried in full
+	if state != nil {
+		for _, page := range pages {
+			state.Scrapes[pageStateKey(page.URL, options)] = scrapeSnapshot{
+				URL:              page.URL,
+				Title:            page.Title,
+				QuestionSelector: options.QuestionSelector,
+				AnswerSelector:   options.AnswerSelector,
+				Updated:          time.Now(),
+				Flashcards:       page.Flashcards,
+			}
+		}
+		if err := state.save(stateFile); err != nil {
+			return fmt.Errorf("saving state file: %w", err)
+		}
+	}
+	return nil
+}
+
+// pageStateKey identifies a page's snapshot in the state file, keeping the keys of earlier
+// selector-only runs unchanged
+func pageStateKey(pageURL string, options extractOptions) string {
+	var extra []string
+	if options.Mode != selectorsMode {
+		extra = append(extra, options.Mode)
+	}
+	if options.LinkSelector != "" {
+		extra = append(extra, options.LinkSelector, options.DetailAnswerSelector)
+	}
+	return scrapeStateKey(pageURL, options.QuestionSelector, options.AnswerSelector, extra...)
+}
@@ -0,0 +1,86 @@
+package url2anki
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestSourceURLs tests that --url, arguments and --url-file are combined without duplicates
+func TestSourceURLs(t *testing.T) {
+	urlFile := filepath.Join(t.TempDir(), "urls.txt")
+	list := "# glossary pages\nhttps://example.com/glossary/b\n\n  https://example.com/glossary/a  \n"
+	if err := os.WriteFile(urlFile, []byte(list), 0600); err != nil {
+		t.Fatalf("Failed to write URL file: %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringArray("url", nil, "")
+	cmd.Flags().String("url-file", "", "")
+	_ = cmd.Flags().Set("url", "https://example.com/glossary/a")
+	_ = cmd.Flags().Set("url-file", urlFile)
+
+	urls, err := sourceURLs(cmd, []string{"https://example.com/glossary/c"})
+	if err != nil {
+		t.Fatalf("sourceURLs returned an error: %v", err)
+	}
+	expected := []string{"https://example.com/glossary/a", "https://example.com/glossary/c", "https://example.com/glossary/b"}
+	if strings.Join(urls, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected URLs %v, got %v", expected, urls)
+	}
+
+	if _, err := sourceURLs(cmd, []string{"glossary"}); err == nil {
+		t.Error("Expected an error for a relative URL")
+	}
+	empty := &cobra.Command{}
+	empty.Flags().StringArray("url", nil, "")
+	empty.Flags().String("url-file", "", "")
+	if _, err := sourceURLs(empty, nil); err == nil {
+		t.Error("Expected an error when no URL is given")
+	}
+}
+
+// TestScrapePages tests that every page is scraped with the same selectors, cards are tagged with their
+// source page and a failing page does not lose the others
+func TestScrapePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			_, _ = w.Write([]byte(`<title>Glossary A</title><dt>API</dt><dd>Application programming interface</dd>`))
+		case "/b":
+			_, _ = w.Write([]byte(`<title>Glossary B</title><dt>Boot</dt><dd>Start a system</dd><dt>Bus</dt><dd>Shared channel</dd>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var msg bytes.Buffer
+	options := extractOptions{Mode: selectorsMode, QuestionSelector: "dt", AnswerSelector: "dd"}
+	pages := scrapePages(&msg, []string{server.URL + "/a", server.URL + "/missing", server.URL + "/b"}, options, nil)
+
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 scraped pages, got %d", len(pages))
+	}
+	if pages[0].Title != "Glossary A" || len(pages[1].Flashcards) != 2 {
+		t.Errorf("Unexpected pages: %+v", pages)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	if tags := pages[1].Flashcards[0].Tags; len(tags) != 1 || tags[0] != "source::"+host+"/b" {
+		t.Errorf("Expected the card to be tagged with its source page, got %v", tags)
+	}
+	if !strings.Contains(msg.String(), "Error scraping "+server.URL+"/missing") || !strings.Contains(msg.String(), "Scraped 2 of 3 pages") {
+		t.Errorf("Expected the failing page to be reported, got %q", msg.String())
+	}
+
+	single := scrapePages(&msg, []string{server.URL + "/a"}, options, nil)
+	if len(single) != 1 || len(single[0].Flashcards[0].Tags) != 0 {
+		t.Errorf("Expected a single page to be left untagged, got %+v", single)
+	}
+}
@@ -0,0 +1,101 @@
+package url2anki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkpointFileVersion is bumped whenever the checkpoint file layout changes incompatibly
+const checkpointFileVersion = 1
+
+// crawlCheckpoint is the on-disk record of a crawl in progress: the pages still to fetch, the pages
+// already fetched and the flashcards scraped from them
+type crawlCheckpoint struct {
+	Version int `json:"version"`
+	// Key identifies the start pages, selectors and follow rules the crawl was started with
+	Key      string        `json:"key"`
+	Frontier []crawlTarget `json:"frontier"`
+	Visited  []string      `json:"visited"`
+	Pages    []pageScrape  `json:"pages"`
+}
+
+// loadCrawlCheckpoint reads the checkpoint file at path, returning nil when it does not exist yet
+func loadCrawlCheckpoint(path string) (*crawlCheckpoint, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path from user CLI arg, expected
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint crawlCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint file %s: %w", path, err)
+	}
+	if checkpoint.Version != checkpointFileVersion {
+		return nil, fmt.Errorf("checkpoint file %s has unsupported version %d", path, checkpoint.Version)
+	}
+	return &checkpoint, nil
+}
+
+// save writes the checkpoint to path through a temporary file, so an interrupted write never
+// leaves a truncated checkpoint behind
+func (c *crawlCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// crawlKey identifies a crawl by its start pages, extraction settings and follow rules, so a checkpoint
+// is only resumed by the crawl that wrote it
+func crawlKey(seeds []string, extract extractOptions, options crawlOptions) string {
+	values := append([]string{}, seeds...)
+	values = append(values, extract.Mode, extract.QuestionSelector, extract.AnswerSelector, extract.ItemSelector, options.Follow)
+	return guidFor(values...)
+}
+
+// checkpoint captures the crawl's progress
+func (c *crawler) checkpoint() *crawlCheckpoint {
+	return &crawlCheckpoint{
+		Version:  checkpointFileVersion,
+		Key:      c.key,
+		Frontier: c.frontier,
+		Visited:  c.visited,
+		Pages:    c.pages,
+	}
+}
+
+// restore continues the crawl recorded in checkpoint, which must have been written by the same crawl
+func (c *crawler) restore(checkpoint *crawlCheckpoint, path string) error {
+	if checkpoint.Key != c.key {
+		return fmt.Errorf("checkpoint file %s was written by a crawl with other start pages, selectors or --follow; remove it to start over", path)
+	}
+	c.frontier = checkpoint.Frontier
+	c.visited = checkpoint.Visited
+	c.pages = checkpoint.Pages
+	c.seen = map[string]bool{}
+	for _, pageURL := range c.visited {
+		c.seen[pageURL] = true
+	}
+	for _, target := range c.frontier {
+		c.seen[target.URL] = true
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package url2anki
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeFlashcardsToCollection upserts the flashcards into a deck of the existing collection.anki2 at path.
+// It refuses to touch the collection while a running Anki holds it open.
+func writeFlashcardsToCollection(flashcards []Flashcard, deckName, path string) (collectionReport, error) {
+	collection, err := openAnkiCollection(expandHome(path))
+	if err != nil {
+		return collectionReport{}, err
+	}
+
+	report, err := collection.addFlashcards(deckName, flashcards)
+	if err != nil {
+		collection.discard()
+		return report, err
+	}
+	return report, collection.Close()
+}
+
+// expandHome replaces a leading ~ with the user's home directory, for paths quoted past the shell
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
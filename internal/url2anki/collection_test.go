@@ -0,0 +1,214 @@
+package url2anki
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteFlashcardsToCollection tests the writeFlashcardsToCollection function
+func TestWriteFlashcardsToCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := writeDeckCollection([]Flashcard{{Question: "Question 1", Answer: "Old answer"}}, "Glossary", path); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1"},
+		{Question: "Question 2", Answer: "Answer 2"},
+	}
+	report, err := writeFlashcardsToCollection(flashcards, "Glossary", path)
+	if err != nil {
+		t.Fatalf("writeFlashcardsToCollection returned an error: %v", err)
+	}
+	if expected := (collectionReport{Added: 1, Updated: 1}); report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open the collection: %v", err)
+	}
+	defer db.Close()
+
+	// Every note and card should be pending sync and new cards should be numbered after the existing one
+	var notes, pendingNotes, cards, pendingCards int
+	if err := db.QueryRow("SELECT count(*), sum(usn = -1) FROM notes").Scan(&notes, &pendingNotes); err != nil {
+		t.Fatalf("Failed to count notes: %v", err)
+	}
+	if err := db.QueryRow("SELECT count(*), sum(usn = -1) FROM cards").Scan(&cards, &pendingCards); err != nil {
+		t.Fatalf("Failed to count cards: %v", err)
+	}
+	if notes != 2 || pendingNotes != 2 || cards != 2 || pendingCards != 2 {
+		t.Errorf("Expected 2 pending notes and cards, got %d/%d notes and %d/%d cards", pendingNotes, notes, pendingCards, cards)
+	}
+
+	var due int
+	if err := db.QueryRow("SELECT c.due FROM cards c JOIN notes n ON n.id = c.nid WHERE n.sfld = 'Question 2'").Scan(&due); err != nil {
+		t.Fatalf("Failed to read card: %v", err)
+	}
+	if due != 2 {
+		t.Errorf("Expected the new card to be due at position 2, got %d", due)
+	}
+}
+
+// TestWriteFlashcardsToCollectionLocked tests that a collection held open by Anki is left alone
+func TestWriteFlashcardsToCollectionLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := writeDeckCollection(nil, "Glossary", path); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// Hold an exclusive lock the way a running Anki does
+	anki, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open the collection: %v", err)
+	}
+	defer anki.Close()
+	anki.SetMaxOpenConns(1)
+	if _, err := anki.Exec("BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("Failed to lock the collection: %v", err)
+	}
+	defer func() { _, _ = anki.Exec("ROLLBACK") }()
+
+	_, err = writeFlashcardsToCollection([]Flashcard{{Question: "Question 1", Answer: "Answer 1"}}, "Glossary", path)
+	if !errors.Is(err, errCollectionLocked) {
+		t.Fatalf("Expected errCollectionLocked, got %v", err)
+	}
+}
+
+// TestWriteFlashcardsToCollectionEscapesHTML tests that scraped text is stored as escaped HTML,
+// and that a re-run with the same text leaves the note unchanged
+func TestWriteFlashcardsToCollectionEscapesHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	flashcards := []Flashcard{{Question: "kubectl logs <pod-name>", Answer: "Prints logs when a < b\nand more"}}
+	if err := writeDeckCollection(flashcards, "Glossary", path); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open the collection: %v", err)
+	}
+	defer db.Close()
+
+	var flds, sfld string
+	if err := db.QueryRow("SELECT flds, sfld FROM notes").Scan(&flds, &sfld); err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	if expected := "kubectl logs &lt;pod-name&gt;\x1fPrints logs when a &lt; b<br>and more"; flds != expected {
+		t.Errorf("Expected fields %q, got %q", expected, flds)
+	}
+	if sfld != "kubectl logs <pod-name>" {
+		t.Errorf("Expected sort field %q, got %q", "kubectl logs <pod-name>", sfld)
+	}
+
+	report, err := writeFlashcardsToCollection(flashcards, "Glossary", path)
+	if err != nil {
+		t.Fatalf("writeFlashcardsToCollection returned an error: %v", err)
+	}
+	if expected := (collectionReport{Unchanged: 1}); report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+}
+
+// TestWriteFlashcardsToModernCollectionWithoutBasic tests that a modern collection lacking the stock Basic
+// note type gets url2anki's own one
+func TestWriteFlashcardsToModernCollectionWithoutBasic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(modernCollectionSchema + "DELETE FROM notetypes; DELETE FROM fields; DELETE FROM templates;"); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	report, err := writeFlashcardsToCollection([]Flashcard{{Question: "Question 1", Answer: "Answer 1"}}, "Glossary", path)
+	if err != nil {
+		t.Fatalf("writeFlashcardsToCollection returned an error: %v", err)
+	}
+	if expected := (collectionReport{Added: 1}); report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+
+	var notetypeID, fields, templates int64
+	if err := db.QueryRow("SELECT id FROM notetypes WHERE name = ?", basicModelName).Scan(&notetypeID); err != nil {
+		t.Fatalf("Failed to read note type: %v", err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM fields WHERE ntid = ?", notetypeID).Scan(&fields); err != nil {
+		t.Fatalf("Failed to count fields: %v", err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM templates WHERE ntid = ?", notetypeID).Scan(&templates); err != nil {
+		t.Fatalf("Failed to count templates: %v", err)
+	}
+	if fields != 2 || templates != 1 {
+		t.Errorf("Expected 2 fields and 1 template, got %d and %d", fields, templates)
+	}
+
+	var mid int64
+	if err := db.QueryRow("SELECT mid FROM notes").Scan(&mid); err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	if mid != notetypeID {
+		t.Errorf("Expected the note to use note type %d, got %d", notetypeID, mid)
+	}
+}
+
+// TestWriteFlashcardsToCollectionReviewTags tests that removed cards only tag their existing notes and
+// that the changed tag is dropped once a card is unchanged again
+func TestWriteFlashcardsToCollectionReviewTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	existing := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1", Tags: []string{"mine", changedTag}},
+		{Question: "Question 2", Answer: "My own answer"},
+	}
+	if err := writeDeckCollection(existing, "Glossary", path); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1"},
+		{Question: "Question 2", Answer: "Answer 2", Tags: []string{removedTag}},
+		{Question: "Question 3", Answer: "Answer 3", Tags: []string{removedTag}},
+	}
+	report, err := writeFlashcardsToCollection(flashcards, "Glossary", path)
+	if err != nil {
+		t.Fatalf("writeFlashcardsToCollection returned an error: %v", err)
+	}
+	if expected := (collectionReport{Updated: 2, Unchanged: 1}); report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open the collection: %v", err)
+	}
+	defer db.Close()
+
+	notes := map[string][2]string{}
+	rows, err := db.Query("SELECT sfld, flds, tags FROM notes")
+	if err != nil {
+		t.Fatalf("Failed to read notes: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sfld, flds, tags string
+		if err := rows.Scan(&sfld, &flds, &tags); err != nil {
+			t.Fatalf("Failed to read note: %v", err)
+		}
+		notes[sfld] = [2]string{flds, tags}
+	}
+
+	if len(notes) != 2 {
+		t.Fatalf("Expected the removed card without a note not to be added, got %d notes", len(notes))
+	}
+	if tags := notes["Question 1"][1]; tags != " mine " {
+		t.Errorf("Expected only the user's tag to remain on Question 1, got %q", tags)
+	}
+	if note := notes["Question 2"]; note[0] != "Question 2\x1fMy own answer" || note[1] != " "+removedTag+" " {
+		t.Errorf("Expected Question 2 to be tagged and keep its fields, got %q", note)
+	}
+}
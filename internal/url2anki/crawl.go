@@ -0,0 +1,352 @@
+package url2anki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/cobra"
+)
+
+// paginationSelector matches the "next page" links a crawl always follows, at the depth of the page they are on
+const paginationSelector = `a[rel~=next][href], link[rel~=next][href]`
+
+// crawlOptions describes which links a crawl follows and when it stops
+type crawlOptions struct {
+	// Follow matches the links followed from every page, on top of pagination links
+	Follow   string
+	MaxDepth int
+	// MaxPages bounds the number of pages fetched, with 0 meaning no limit
+	MaxPages int
+	// SameHost keeps the crawl on the hosts of its start pages
+	SameHost bool
+	// Checkpoint is the file the crawl's progress is recorded in after every page, and Resume
+	// continues the crawl recorded there
+	Checkpoint string
+	Resume     bool
+}
+
+// crawlTarget is a page waiting to be fetched, Depth links away from a start page
+type crawlTarget struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// crawler walks a site breadth first from its start pages, scraping flashcards from every page it fetches
+type crawler struct {
+	options crawlOptions
+	extract extractOptions
+	follow  selectorPart
+	hosts   map[string]bool
+	key     string
+
+	// frontier holds the pages still to fetch, in order
+	frontier []crawlTarget
+	// visited lists the pages already fetched, in order
+	visited []string
+	// seen holds every normalized URL fetched or queued, so each page is fetched once
+	seen  map[string]bool
+	pages []pageScrape
+}
+
+// crawlOptionsFromFlags builds the crawl options from the command line flags
+func crawlOptionsFromFlags(cmd *cobra.Command) crawlOptions {
+	follow, _ := cmd.Flags().GetString("follow")
+	maxDepth, _ := cmd.Flags().GetInt("max-depth")
+	maxPages, _ := cmd.Flags().GetInt("max-pages")
+	sameHost, _ := cmd.Flags().GetBool("same-host")
+	checkpoint, _ := cmd.Flags().GetString("checkpoint")
+	resume, _ := cmd.Flags().GetBool("resume")
+	return crawlOptions{
+		Follow:     follow,
+		MaxDepth:   maxDepth,
+		MaxPages:   maxPages,
+		SameHost:   sameHost,
+		Checkpoint: checkpoint,
+		Resume:     resume,
+	}
+}
+
+// validate checks that the limits are usable and the follow selector parses
+func (o crawlOptions) validate() error {
+	if o.MaxDepth < 0 {
+		return errors.New("--max-depth must not be negative")
+	}
+	if o.MaxPages < 0 {
+		return errors.New("--max-pages must not be negative")
+	}
+	if o.Resume && o.Checkpoint == "" {
+		return errors.New("--resume needs the --checkpoint file of the crawl to continue")
+	}
+	if o.Follow != "" {
+		if _, err := parseSelectorPart(o.Follow); err != nil {
+			return fmt.Errorf("invalid --follow selector: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateCrawl fails before any page is fetched when the start pages, selectors or crawl limits are invalid
+func ValidateCrawl(cmd *cobra.Command, args []string) error {
+	if err := ValidateScrape(cmd, args); err != nil {
+		return err
+	}
+	return crawlOptionsFromFlags(cmd).validate()
+}
+
+// Crawl discovers pages by following links from the start pages, scrapes every page with the same
+// selectors and delivers all the flashcards as one deck. With a checkpoint file, progress is recorded
+// after every page so an interrupted crawl can be resumed. An interrupt (Ctrl-C) stops the crawl and
+// exports the flashcards collected so far. Any failure is returned so the process exits non-zero.
+func Crawl(cmd *cobra.Command, args []string) error {
+	outputs, err := resolveOutputs(cmd)
+	if err != nil {
+		return err
+	}
+	msg := messageWriter(outputs)
+
+	seeds, err := sourceURLs(cmd, args)
+	if err != nil {
+		return err
+	}
+	entries, err := sitemapPages(cmd, msg)
+	if err != nil {
+		return fmt.Errorf("reading sitemap: %w", err)
+	}
+	seeds = appendEntryURLs(seeds, entries)
+	if len(seeds) == 0 {
+		return errors.New("no page in the sitemap matched --url-pattern and --since")
+	}
+	options := crawlOptionsFromFlags(cmd)
+	c, err := newCrawler(seeds, options, extractOptionsFromFlags(cmd))
+	if err != nil {
+		return err
+	}
+	if options.Resume {
+		checkpoint, err := loadCrawlCheckpoint(options.Checkpoint)
+		if err != nil {
+			return fmt.Errorf("loading checkpoint file: %w", err)
+		}
+		if checkpoint != nil {
+			if err := c.restore(checkpoint, options.Checkpoint); err != nil {
+				return err
+			}
+			fmt.Fprintf(msg, "Resuming crawl with %d pages visited and %d queued\n", len(c.visited), len(c.frontier))
+		}
+	}
+
+	// Stop between pages on the first Ctrl-C, then let a second one kill the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	pages := c.run(ctx, msg)
+	interrupted := ctx.Err() != nil
+	stop()
+	if interrupted {
+		fmt.Fprintf(msg, "Interrupted after %d pages with %d still queued; exporting the flashcards collected so far\n", len(c.visited), len(c.frontier))
+		if options.Checkpoint != "" {
+			fmt.Fprintln(msg, "Rerun with --resume to continue the crawl")
+		}
+	}
+	if len(pages) == 0 {
+		return errors.New("crawling: none of the pages could be scraped")
+	}
+	if err := deliverPages(cmd, msg, outputs, pages, c.extract); err != nil {
+		return err
+	}
+
+	// A finished crawl starts over next time, so its checkpoint is only kept while pages remain queued
+	if len(c.frontier) == 0 && options.Checkpoint != "" {
+		if err := os.Remove(options.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing checkpoint file: %w", err)
+		}
+	}
+	return nil
+}
+
+// newCrawler prepares a crawl starting from seeds
+func newCrawler(seeds []string, options crawlOptions, extract extractOptions) (*crawler, error) {
+	c := &crawler{
+		options: options,
+		extract: extract,
+		hosts:   map[string]bool{},
+		key:     crawlKey(seeds, extract, options),
+		seen:    map[string]bool{},
+	}
+	if options.Follow != "" {
+		follow, err := parseSelectorPart(options.Follow)
+		if err != nil {
+			return nil, err
+		}
+		c.follow = follow
+	}
+	for _, seed := range seeds {
+		parsed, err := url.Parse(seed)
+		if err != nil {
+			return nil, err
+		}
+		c.hosts[strings.ToLower(parsed.Host)] = true
+	}
+	for _, seed := range seeds {
+		c.enqueue(seed, 0)
+	}
+	return c, nil
+}
+
+// enqueue adds the page at rawURL to the frontier unless it was seen before, is not a web page or
+// lies off the start hosts of a same-host crawl
+func (c *crawler) enqueue(rawURL string, depth int) {
+	pageURL, err := normalizeCrawlURL(rawURL)
+	if err != nil || c.seen[pageURL] {
+		return
+	}
+	if c.options.SameHost {
+		parsed, _ := url.Parse(pageURL)
+		if !c.hosts[parsed.Host] {
+			return
+		}
+	}
+	c.seen[pageURL] = true
+	c.frontier = append(c.frontier, crawlTarget{URL: pageURL, Depth: depth})
+}
+
+// run fetches pages breadth first until the frontier is empty, the page limit is reached or ctx is done,
+// and returns every page that was scraped, each card tagged with its source page
+func (c *crawler) run(ctx context.Context, msg io.Writer) []pageScrape {
+	for len(c.frontier) > 0 && (c.options.MaxPages == 0 || len(c.visited) < c.options.MaxPages) {
+		if !c.visit(ctx, msg, c.frontier[0]) {
+			return c.pages
+		}
+		if c.options.Checkpoint != "" {
+			if err := c.checkpoint().save(c.options.Checkpoint); err != nil {
+				fmt.Fprintln(msg, "Error saving checkpoint file: ", err)
+			}
+		}
+	}
+	if len(c.frontier) > 0 {
+		fmt.Fprintf(msg, "Stopped after %d pages with %d more queued; raise --max-pages to crawl further\n", len(c.visited), len(c.frontier))
+	}
+	return c.pages
+}
+
+// visit fetches the target page at the head of the frontier, scrapes its flashcards and queues the links
+// it leads to. It returns false, leaving the page queued, when ctx is done before the page and its
+// detail pages were fetched.
+func (c *crawler) visit(ctx context.Context, msg io.Writer, target crawlTarget) bool {
+	doc, err := fetchDocumentContext(ctx, target.URL)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		c.frontier = c.frontier[1:]
+		c.visited = append(c.visited, target.URL)
+		fmt.Fprintf(msg, "Error crawling %s: %v\n", target.URL, err)
+		return true
+	}
+	page, err := scrapeDocument(ctx, target.URL, doc, c.extract)
+	if ctx.Err() != nil {
+		return false
+	}
+	c.frontier = c.frontier[1:]
+	c.visited = append(c.visited, target.URL)
+
+	// A page whose cards cannot be extracted may still lead to pages whose cards can
+	if err != nil {
+		fmt.Fprintf(msg, "Error scraping %s: %v\n", target.URL, err)
+	} else {
+		printSkippedItems(msg, page.Skipped)
+		page.tagSource()
+		fmt.Fprintf(msg, "Scraped %d flashcards from %s\n", len(page.Flashcards), target.URL)
+		c.pages = append(c.pages, page)
+	}
+
+	// Pagination continues the same page, so it does not count towards the depth
+	for _, link := range pageLinks(doc, target.URL, selectorPart{Selector: paginationSelector}) {
+		c.enqueue(link, target.Depth)
+	}
+	if c.options.Follow == "" || target.Depth >= c.options.MaxDepth {
+		return true
+	}
+	for _, link := range pageLinks(doc, target.URL, c.follow) {
+		c.enqueue(link, target.Depth+1)
+	}
+	return true
+}
+
+// pageLinks returns the absolute http(s) URLs of the links matched by selector in doc, resolved against
+// pageURL. The URL is read from the selector's @attr, or href by default; matched elements without it
+// contribute the links inside them.
+func pageLinks(doc *goquery.Document, pageURL string, selector selectorPart) []string {
+	base, err := documentBase(doc, pageURL)
+	if err != nil {
+		return nil
+	}
+	attrName := selector.Attr
+	if attrName == "" {
+		attrName = "href"
+	}
+
+	var links []string
+	resolve := func(ref string) {
+		link, err := base.Parse(strings.TrimSpace(ref))
+		if err == nil && (link.Scheme == "http" || link.Scheme == "https") {
+			links = append(links, link.String())
+		}
+	}
+	selector.find(doc.Selection).Each(func(_ int, s *goquery.Selection) {
+		if ref, ok := s.Attr(attrName); ok {
+			resolve(ref)
+			return
+		}
+		s.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+			resolve(a.AttrOr("href", ""))
+		})
+	})
+	return links
+}
+
+// documentBase returns the URL the relative links in doc resolve against: its <base href> if any,
+// otherwise the page's own URL
+func documentBase(doc *goquery.Document, pageURL string) (*url.URL, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if resolved, err := base.Parse(href); err == nil {
+			base = resolved
+		}
+	}
+	return base, nil
+}
+
+// normalizeCrawlURL returns the form of rawURL used to tell pages apart: the fragment is dropped,
+// the scheme and host are lowercased, an empty path becomes / and query parameters are sorted
+func normalizeCrawlURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("not a web page: %s", rawURL)
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = parsed.Query().Encode()
+	}
+	return parsed.String(), nil
+}
@@ -0,0 +1,137 @@
+package url2anki
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeCrawlURL tests that fragments are dropped and query parameters sorted
+func TestNormalizeCrawlURL(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.com/docs?b=2&a=1#intro": "https://example.com/docs?a=1&b=2",
+		"https://example.com":                    "https://example.com/",
+		"http://example.com/glossary/#top":       "http://example.com/glossary/",
+	}
+	for input, expected := range tests {
+		got, err := normalizeCrawlURL(input)
+		if err != nil {
+			t.Fatalf("normalizeCrawlURL(%q) returned an error: %v", input, err)
+		}
+		if got != expected {
+			t.Errorf("normalizeCrawlURL(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+	if _, err := normalizeCrawlURL("mailto:docs@example.com"); err == nil {
+		t.Error("Expected an error for a mailto link")
+	}
+}
+
+// TestCrawl tests that the crawler follows links and pagination within the depth limit, fetches each
+// normalized URL once and stays on the start host
+func TestCrawl(t *testing.T) {
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<nav class="toc"><a href="/a?y=2&x=1">A</a><a href="/a?x=1&y=2#top">A again</a><a href="https://elsewhere.example/">Off site</a></nav>`)
+		case "/a":
+			fmt.Fprint(w, `<dt>API</dt><dd>Interface</dd><a rel="next" href="/a2">Next</a><nav class="toc"><a href="/deep">Deep</a></nav>`)
+		case "/a2":
+			fmt.Fprint(w, `<dt>Auth</dt><dd>Identity check</dd><nav class="toc"><a href="/deep">Deep</a></nav>`)
+		default:
+			fmt.Fprint(w, `<dt>Deep</dt><dd>Too far</dd>`)
+		}
+	}))
+	defer server.Close()
+
+	options := crawlOptions{Follow: "nav.toc a", MaxDepth: 1, SameHost: true}
+	c, err := newCrawler([]string{server.URL + "/"}, options, extractOptions{Mode: selectorsMode, QuestionSelector: "dt", AnswerSelector: "dd"})
+	if err != nil {
+		t.Fatalf("newCrawler returned an error: %v", err)
+	}
+	var msg bytes.Buffer
+	pages := c.run(context.Background(), &msg)
+
+	expected := []string{"/", "/a?x=1&y=2", "/a2"}
+	if strings.Join(fetched, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected to fetch %v, got %v", expected, fetched)
+	}
+	var questions []string
+	for _, page := range pages {
+		for _, flashcard := range page.Flashcards {
+			questions = append(questions, flashcard.Question)
+		}
+	}
+	if strings.Join(questions, ",") != "API,Auth" {
+		t.Errorf("Expected cards API and Auth, got %v", questions)
+	}
+
+	// The page limit stops the crawl and reports what was left
+	fetched = nil
+	options.MaxPages = 1
+	c, _ = newCrawler([]string{server.URL + "/"}, options, extractOptions{Mode: selectorsMode, QuestionSelector: "dt", AnswerSelector: "dd"})
+	c.run(context.Background(), &msg)
+	if len(fetched) != 1 || !strings.Contains(msg.String(), "Stopped after 1 pages") {
+		t.Errorf("Expected the crawl to stop after one page, fetched %v", fetched)
+	}
+}
+
+// TestCrawlCheckpoint tests that an interrupted crawl keeps its frontier and that resuming from the
+// checkpoint fetches only the pages left and keeps the cards collected before
+func TestCrawlCheckpoint(t *testing.T) {
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		next := map[string]string{"/1": "/2", "/2": "/3"}[r.URL.Path]
+		fmt.Fprintf(w, `<dt>Term %s</dt><dd>Definition</dd><a rel="next" href="%s">Next</a>`, r.URL.Path, next)
+	}))
+	defer server.Close()
+
+	checkpointFile := filepath.Join(t.TempDir(), "crawl.json")
+	seeds := []string{server.URL + "/1"}
+	extract := extractOptions{Mode: selectorsMode, QuestionSelector: "dt", AnswerSelector: "dd"}
+	options := crawlOptions{MaxPages: 1, Checkpoint: checkpointFile}
+
+	var msg bytes.Buffer
+	c, _ := newCrawler(seeds, options, extract)
+	c.run(context.Background(), &msg)
+
+	// An interrupt before the next fetch leaves the page queued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	options.MaxPages = 0
+	c.options = options
+	c.run(ctx, &msg)
+	if len(c.frontier) != 1 || len(fetched) != 1 {
+		t.Fatalf("Expected the interrupted crawl to keep its frontier, got %v after fetching %v", c.frontier, fetched)
+	}
+
+	checkpoint, err := loadCrawlCheckpoint(checkpointFile)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("Expected a checkpoint, got %v (%v)", checkpoint, err)
+	}
+	resumed, _ := newCrawler(seeds, options, extract)
+	if err := resumed.restore(checkpoint, checkpointFile); err != nil {
+		t.Fatalf("restore returned an error: %v", err)
+	}
+	pages := resumed.run(context.Background(), &msg)
+
+	if strings.Join(fetched, " ") != "/1 /2 /3" {
+		t.Errorf("Expected every page to be fetched once, got %v", fetched)
+	}
+	if len(pages) != 3 || pages[0].Flashcards[0].Question != "Term /1" {
+		t.Errorf("Expected the resumed crawl to keep the earlier cards, got %+v", pages)
+	}
+
+	other, _ := newCrawler(seeds, options, extractOptions{Mode: "dl"})
+	if err := other.restore(checkpoint, checkpointFile); err == nil {
+		t.Error("Expected an error resuming a checkpoint written with other selectors")
+	}
+}
@@ -0,0 +1,281 @@
+package url2anki
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Quoting styles understood by CSVDialect.Quote
+const (
+	quoteMinimal = "minimal"
+	quoteAll     = "all"
+	quoteNone    = "none"
+)
+
+// utf8BOM is written at the start of files when CSVDialect.BOM is set, for spreadsheet apps that need it
+const utf8BOM = "\ufeff"
+
+// CSVDialect controls how delimited exports are written
+type CSVDialect struct {
+	// Delimiter separates fields; zero means the format's default
+	Delimiter rune
+	// Quote is one of "minimal", "all" or "none"
+	Quote string
+	// BOM prefixes the file with a UTF-8 byte order mark
+	BOM bool
+	// NoHeader suppresses the header row
+	NoHeader bool
+}
+
+// defaultCSVDialect writes plain comma-separated values with a header row
+var defaultCSVDialect = CSVDialect{Delimiter: ',', Quote: quoteMinimal}
+
+// parseDelimiter accepts a single character or one of the names tab, comma, semicolon, space, pipe and colon
+func parseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "space":
+		return ' ', nil
+	case "pipe":
+		return '|', nil
+	case "colon":
+		return ':', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("invalid delimiter %q: expected a single character", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r, nil
+}
+
+// withDefaults fills in the format's default delimiter and quoting
+func (d CSVDialect) withDefaults(delimiter rune) CSVDialect {
+	if d.Delimiter == 0 {
+		d.Delimiter = delimiter
+	}
+	if d.Quote == "" {
+		d.Quote = quoteMinimal
+	}
+	return d
+}
+
+// validate checks the dialect's quoting style
+func (d CSVDialect) validate() error {
+	switch d.Quote {
+	case quoteMinimal, quoteAll, quoteNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid quoting %q: expected %s, %s or %s", d.Quote, quoteMinimal, quoteAll, quoteNone)
+	}
+}
+
+// delimitedWriter writes records using a CSVDialect
+type delimitedWriter struct {
+	w       *bufio.Writer
+	dialect CSVDialect
+}
+
+// newDelimitedWriter returns a writer for dialect, writing the BOM straight away when requested
+func newDelimitedWriter(w io.Writer, dialect CSVDialect) (*delimitedWriter, error) {
+	if err := dialect.validate(); err != nil {
+		return nil, err
+	}
+	dw := &delimitedWriter{w: bufio.NewWriter(w), dialect: dialect}
+	if dialect.BOM {
+		if _, err := dw.w.WriteString(utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+	return dw, nil
+}
+
+// writeLine writes a raw line, used for the header lines of Anki text files
+func (dw *delimitedWriter) writeLine(line string) error {
+	_, err := dw.w.WriteString(line + "\n")
+	return err
+}
+
+// write writes a single record
+func (dw *delimitedWriter) write(record []string) error {
+	delimiter := string(dw.dialect.Delimiter)
+
+	switch dw.dialect.Quote {
+	case quoteAll:
+		quoted := make([]string, len(record))
+		for i, field := range record {
+			quoted[i] = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+		return dw.writeLine(strings.Join(quoted, delimiter))
+	case quoteNone:
+		for _, field := range record {
+			if strings.ContainsAny(field, delimiter+"\r\n") {
+				return fmt.Errorf("field %q needs quoting but quoting is disabled", field)
+			}
+		}
+		return dw.writeLine(strings.Join(record, delimiter))
+	default:
+		var line strings.Builder
+		writer := csv.NewWriter(&line)
+		writer.Comma = dw.dialect.Delimiter
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		_, err := dw.w.WriteString(line.String())
+		return err
+	}
+}
+
+// flush writes any buffered data to the underlying writer
+func (dw *delimitedWriter) flush() error {
+	return dw.w.Flush()
+}
+
+// flashcardColumns returns the column names and rows for the flashcards,
+// with GUID and Tags columns only when the cards carry them
+func flashcardColumns(flashcards []Flashcard, question, answer string, format func(string) string) ([]string, [][]string) {
+	withGUID, withTags := false, false
+	for _, flashcard := range flashcards {
+		withGUID = withGUID || flashcard.GUID != ""
+		withTags = withTags || len(flashcard.Tags) > 0
+	}
+
+	header := []string{question, answer}
+	if withGUID {
+		header = append(header, "GUID")
+	}
+	if withTags {
+		header = append(header, "Tags")
+	}
+
+	rows := make([][]string, 0, len(flashcards))
+	for _, flashcard := range flashcards {
+		row := []string{format(flashcard.Question), format(flashcard.Answer)}
+		if withGUID {
+			row = append(row, flashcard.GUID)
+		}
+		if withTags {
+			row = append(row, strings.Join(flashcard.Tags, " "))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// exportFlashcardsToCSVFileWithDialect exports the flashcards to a delimited file written with dialect
+func exportFlashcardsToCSVFileWithDialect(flashcards []Flashcard, filename string, dialect CSVDialect) error {
+	file, err := os.Create(filename) //#nosec G304
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := newDelimitedWriter(file, dialect.withDefaults(','))
+	if err != nil {
+		return err
+	}
+
+	header, rows := flashcardColumns(flashcards, "Question", "Answer", func(s string) string { return s })
+	if !dialect.NoHeader {
+		if err := writer.write(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if err := writer.write(row); err != nil {
+			return err
+		}
+	}
+
+	return writer.flush()
+}
+
+// ankiSeparatorNames are the separator names Anki's text importer understands in #separator headers
+var ankiSeparatorNames = map[rune]string{
+	'\t': "tab",
+	',':  "comma",
+	';':  "semicolon",
+	' ':  "space",
+	'|':  "pipe",
+	':':  "colon",
+}
+
+// exportFlashcardsToAnkiTextFile exports the flashcards to a plain-text file whose header lines tell
+// Anki's importer the separator, deck, note type and GUID/tags columns, so no manual mapping is needed
+func exportFlashcardsToAnkiTextFile(flashcards []Flashcard, deckName, filename string, dialect CSVDialect) error {
+	dialect = dialect.withDefaults('\t')
+	separator, ok := ankiSeparatorNames[dialect.Delimiter]
+	if !ok {
+		separator = string(dialect.Delimiter)
+	}
+
+	file, err := os.Create(filename) //#nosec G304
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := newDelimitedWriter(file, dialect)
+	if err != nil {
+		return err
+	}
+
+	// Fields are HTML, so escape the scraped text and keep its line breaks
+	header, rows := flashcardColumns(flashcards, "Front", "Back", ankiFieldHTML)
+
+	lines := []string{
+		"#separator:" + separator,
+		"#html:true",
+	}
+	if deckName != "" {
+		lines = append(lines, "#deck:"+deckName)
+	}
+	lines = append(lines, "#notetype:"+stockBasicModelName)
+	for i, column := range header {
+		switch column {
+		case "GUID":
+			lines = append(lines, "#guid column:"+strconv.Itoa(i+1))
+		case "Tags":
+			lines = append(lines, "#tags column:"+strconv.Itoa(i+1))
+		}
+	}
+	if !dialect.NoHeader {
+		lines = append(lines, "#columns:"+strings.Join(header, string(dialect.Delimiter)))
+	}
+	for _, line := range lines {
+		if strings.ContainsAny(line[1:], "\r\n") {
+			return errors.New("file header values must not contain line breaks")
+		}
+		if err := writer.writeLine(line); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		if err := writer.write(row); err != nil {
+			return err
+		}
+	}
+
+	return writer.flush()
+}
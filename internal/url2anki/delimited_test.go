@@ -0,0 +1,119 @@
+package url2anki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestExportFlashcardsToAnkiTextFile tests the exportFlashcardsToAnkiTextFile function
+func TestExportFlashcardsToAnkiTextFile(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Pod", Answer: "Runs <containers>", GUID: "guid1", Tags: []string{"k8s"}},
+		{Question: "Node", Answer: "A worker\nmachine", GUID: "guid2"},
+	}
+	filename := filepath.Join(t.TempDir(), "deck.txt")
+
+	if err := exportFlashcardsToAnkiTextFile(flashcards, "Glossary", filename, CSVDialect{}); err != nil {
+		t.Fatalf("exportFlashcardsToAnkiTextFile returned an error: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read the file: %v", err)
+	}
+
+	expected := "#separator:tab\n" +
+		"#html:true\n" +
+		"#deck:Glossary\n" +
+		"#notetype:Basic\n" +
+		"#guid column:3\n" +
+		"#tags column:4\n" +
+		"#columns:Front\tBack\tGUID\tTags\n" +
+		"Pod\tRuns &lt;containers&gt;\tguid1\tk8s\n" +
+		"Node\tA worker<br>machine\tguid2\t\n"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
+
+// TestExportFlashcardsToCSVFileWithDialect tests the CSV dialect options
+func TestExportFlashcardsToCSVFileWithDialect(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer; with semicolon"},
+		{Question: "Question 2", Answer: "Answer 2"},
+	}
+
+	tests := []struct {
+		name     string
+		dialect  CSVDialect
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			dialect:  CSVDialect{},
+			expected: "Question,Answer\nQuestion 1,Answer; with semicolon\nQuestion 2,Answer 2\n",
+		},
+		{
+			name:     "semicolon with BOM and no header",
+			dialect:  CSVDialect{Delimiter: ';', BOM: true, NoHeader: true},
+			expected: utf8BOM + "Question 1;\"Answer; with semicolon\"\nQuestion 2;Answer 2\n",
+		},
+		{
+			name:     "quote all",
+			dialect:  CSVDialect{Quote: quoteAll, NoHeader: true},
+			expected: "\"Question 1\",\"Answer; with semicolon\"\n\"Question 2\",\"Answer 2\"\n",
+		},
+		{
+			name:    "quote none with a delimiter in a field",
+			dialect: CSVDialect{Delimiter: ';', Quote: quoteNone},
+			wantErr: true,
+		},
+		{
+			name:    "unknown quoting",
+			dialect: CSVDialect{Quote: "sometimes"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "deck.csv")
+			err := exportFlashcardsToCSVFileWithDialect(flashcards, filename, tt.dialect)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exportFlashcardsToCSVFileWithDialect returned an error: %v", err)
+			}
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read the file: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+// TestParseDelimiter tests the parseDelimiter function
+func TestParseDelimiter(t *testing.T) {
+	tests := map[string]rune{"": 0, "tab": '\t', "Semicolon": ';', "|": '|'}
+	for input, expected := range tests {
+		got, err := parseDelimiter(input)
+		if err != nil || got != expected {
+			t.Errorf("parseDelimiter(%q) = %q, %v, expected %q", input, got, err, expected)
+		}
+	}
+	for _, input := range []string{"ab", `"`} {
+		if _, err := parseDelimiter(input); err == nil {
+			t.Errorf("parseDelimiter(%q) expected an error", input)
+		}
+	}
+}
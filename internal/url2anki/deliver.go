@@ -0,0 +1,97 @@
+package url2anki
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// deliverFlashcards writes the flashcards to every output file and pushes them to the collection,
+// AnkiConnect and sync server destinations requested on the command line
+func deliverFlashcards(cmd *cobra.Command, msg io.Writer, outputs []outputTarget, flashcards []Flashcard, deckName string) error {
+	ankiConnect, _ := cmd.Flags().GetString("anki-connect")
+	updateExisting, _ := cmd.Flags().GetBool("update-existing")
+	syncServer, _ := cmd.Flags().GetString("sync-server")
+	syncUser, _ := cmd.Flags().GetString("sync-user")
+	syncPassword, _ := cmd.Flags().GetString("sync-password")
+	collectionFile, _ := cmd.Flags().GetString("collection")
+
+	// Export to every requested file
+	options, err := exportOptionsFromFlags(cmd, deckName)
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		// Files cannot tag existing notes, so cards removed upstream only travel on in the card stream
+		exported := flashcards
+		if output.Exporter.Name() != (jsonLinesExporter{}).Name() {
+			exported = slices.DeleteFunc(slices.Clone(flashcards), isRemovedFlashcard)
+		}
+		if err := output.Exporter.Export(exported, options, output.Filename); err != nil {
+			return fmt.Errorf("exporting flashcards to %s file: %w", output.Exporter.Name(), err)
+		}
+		if output.Filename != stdoutFilename {
+			fmt.Fprintf(msg, "Flashcards exported to %s\n", output.Filename)
+		}
+	}
+
+	// Write straight into an existing Anki collection
+	if collectionFile != "" {
+		report, err := writeFlashcardsToCollection(flashcards, deckName, collectionFile)
+		if err != nil {
+			return fmt.Errorf("writing flashcards to Anki collection: %w", err)
+		}
+		fmt.Fprintf(msg, "Wrote deck %q to %s: %d added, %d updated, %d unchanged\n", deckName, collectionFile, report.Added, report.Updated, report.Unchanged)
+	}
+
+	// Push to a running Anki via AnkiConnect
+	if ankiConnect != "" {
+		request := AnkiSyncRequest{DeckName: deckName, Flashcards: flashcards}
+		report, err := pushToAnkiConnect(newAnkiConnectClient(ankiConnect), request, updateExisting)
+		if err != nil {
+			return fmt.Errorf("pushing flashcards to AnkiConnect: %w", err)
+		}
+		fmt.Fprintf(msg, "Added %d flashcards to deck %q via AnkiConnect\n", report.Added, deckName)
+		if len(report.Existing) > 0 {
+			fmt.Fprintf(msg, "Matched %d flashcards to the notes of earlier runs by their GUID tag\n", len(report.Existing))
+		}
+		if updateExisting {
+			fmt.Fprintf(msg, "Updated %d existing flashcards whose answer changed\n", report.Updated)
+		}
+		for _, duplicate := range report.Duplicates {
+			fmt.Fprintf(msg, "Duplicate rejected by Anki: %s\n", duplicate.Question)
+		}
+		for _, failed := range report.Failed {
+			fmt.Fprintf(msg, "Failed to add to Anki: %s\n", failed.Question)
+		}
+		if len(report.Failed) > 0 {
+			return fmt.Errorf("pushing flashcards to AnkiConnect: %d of %d flashcards could not be added", len(report.Failed), len(flashcards))
+		}
+	}
+
+	// Push to a self-hosted Anki sync server
+	if syncServer != "" {
+		request := AnkiSyncRequest{DeckName: deckName, Flashcards: flashcards}
+		report, err := syncFlashcardsToServer(newAnkiSyncClient(syncServer), syncUser, syncPassword, request)
+		if err != nil {
+			return fmt.Errorf("syncing flashcards to sync server: %w", err)
+		}
+		fmt.Fprintf(msg, "Synced deck %q to %s: %d added, %d updated, %d unchanged\n", deckName, syncServer, report.Added, report.Updated, report.Unchanged)
+	}
+
+	return nil
+}
+
+// messageWriter returns where progress and error messages go: stderr when the cards themselves are streamed
+// to stdout, stdout otherwise
+func messageWriter(outputs []outputTarget) io.Writer {
+	for _, output := range outputs {
+		if output.Filename == stdoutFilename {
+			return os.Stderr
+		}
+	}
+	return os.Stdout
+}
@@ -0,0 +1,188 @@
+package url2anki
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Exporter writes flashcards to a file in a single format
+type Exporter interface {
+	// Name is the format name accepted by --format
+	Name() string
+	// Extensions are the file extensions, including the leading dot, that select this exporter
+	Extensions() []string
+	// Export writes the flashcards to filename
+	Export(flashcards []Flashcard, options ExportOptions, filename string) error
+}
+
+// ExportOptions carries the settings shared by every exporter
+type ExportOptions struct {
+	// DeckName is the Anki deck the flashcards belong to
+	DeckName string
+	// CSV controls the delimiter, quoting, BOM and header row of delimited exports
+	CSV CSVDialect
+}
+
+// exporters holds every registered Exporter keyed by format name
+var exporters = map[string]Exporter{}
+
+// registerExporter makes an Exporter available by its name and extensions
+func registerExporter(exporter Exporter) {
+	exporters[exporter.Name()] = exporter
+}
+
+func init() {
+	registerExporter(jsonExporter{})
+	registerExporter(csvExporter{})
+	registerExporter(apkgExporter{})
+	registerExporter(ankiTextExporter{})
+	registerExporter(jsonLinesExporter{})
+}
+
+// exporterFor returns the exporter named by format, or the one matching the filename's extension when format is empty
+func exporterFor(filename, format string) (Exporter, error) {
+	// Only the card stream can be written to stdout
+	if filename == stdoutFilename {
+		if format != "" && format != (jsonLinesExporter{}).Name() {
+			return nil, fmt.Errorf("only the %s format can be written to stdout", (jsonLinesExporter{}).Name())
+		}
+		return exporters[(jsonLinesExporter{}).Name()], nil
+	}
+
+	if format != "" {
+		exporter, ok := exporters[strings.ToLower(format)]
+		if !ok {
+			return nil, fmt.Errorf("unknown export format %q (supported: %s)", format, strings.Join(exporterNames(), ", "))
+		}
+		return exporter, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, name := range exporterNames() {
+		for _, candidate := range exporters[name].Extensions() {
+			if ext == candidate {
+				return exporters[name], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot tell the export format of %q from its extension, use --format (supported: %s)", filename, strings.Join(exporterNames(), ", "))
+}
+
+// exporterNames returns the registered format names in sorted order
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputTarget is a file to export to together with the exporter that writes it
+type outputTarget struct {
+	Filename string
+	Exporter Exporter
+}
+
+// resolveOutputs returns the export targets requested on the command line.
+// Repeated --output flags take precedence over the single --output-file, which is only used when it was set
+// explicitly or when no other destination, file or otherwise, was chosen. --format only applies to a single output.
+func resolveOutputs(cmd *cobra.Command) ([]outputTarget, error) {
+	outputFile, _ := cmd.Flags().GetString("output-file")
+	outputs, _ := cmd.Flags().GetStringArray("output")
+	format, _ := cmd.Flags().GetString("format")
+	collectionFile, _ := cmd.Flags().GetString("collection")
+	ankiConnect, _ := cmd.Flags().GetString("anki-connect")
+	syncServer, _ := cmd.Flags().GetString("sync-server")
+
+	otherDestination := len(outputs) > 0 || collectionFile != "" || ankiConnect != "" || syncServer != ""
+	if outputFile != "" && (!otherDestination || cmd.Flags().Changed("output-file")) {
+		outputs = append([]string{outputFile}, outputs...)
+	}
+	if format != "" && len(outputs) > 1 {
+		return nil, errors.New("--format applies to a single output; with several, each output's format comes from its file extension")
+	}
+
+	var targets []outputTarget
+	for _, output := range outputs {
+		exporter, err := exporterFor(output, format)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, outputTarget{Filename: output, Exporter: exporter})
+	}
+	return targets, nil
+}
+
+// ValidateOutputs fails before any scraping happens when an output's format or dialect is invalid
+func ValidateOutputs(cmd *cobra.Command, args []string) error {
+	if _, err := resolveOutputs(cmd); err != nil {
+		return err
+	}
+	syncServer, _ := cmd.Flags().GetString("sync-server")
+	forceFullUpload, _ := cmd.Flags().GetBool("force-full-upload")
+	if syncServer != "" && !forceFullUpload {
+		return errors.New("--sync-server replaces the server's whole collection, losing changes another device syncs meanwhile; sync every device first and pass --force-full-upload")
+	}
+	_, err := exportOptionsFromFlags(cmd, "")
+	return err
+}
+
+// jsonExporter writes flashcards as an indented JSON array
+type jsonExporter struct{}
+
+func (jsonExporter) Name() string         { return "json" }
+func (jsonExporter) Extensions() []string { return []string{".json"} }
+func (jsonExporter) Export(flashcards []Flashcard, options ExportOptions, filename string) error {
+	return exportFlashcardsToJSONFile(flashcards, filename)
+}
+
+// csvExporter writes flashcards as CSV with a header row
+type csvExporter struct{}
+
+func (csvExporter) Name() string         { return "csv" }
+func (csvExporter) Extensions() []string { return []string{".csv"} }
+func (csvExporter) Export(flashcards []Flashcard, options ExportOptions, filename string) error {
+	return exportFlashcardsToCSVFileWithDialect(flashcards, filename, options.CSV)
+}
+
+// apkgExporter writes flashcards as an Anki package holding a single deck
+type apkgExporter struct{}
+
+func (apkgExporter) Name() string         { return "apkg" }
+func (apkgExporter) Extensions() []string { return []string{".apkg"} }
+func (apkgExporter) Export(flashcards []Flashcard, options ExportOptions, filename string) error {
+	return exportFlashcardsToAPKGFile(flashcards, options.DeckName, filename)
+}
+
+// ankiTextExporter writes flashcards as an Anki plain-text import file with file headers
+type ankiTextExporter struct{}
+
+func (ankiTextExporter) Name() string         { return "txt" }
+func (ankiTextExporter) Extensions() []string { return []string{".txt", ".tsv"} }
+func (ankiTextExporter) Export(flashcards []Flashcard, options ExportOptions, filename string) error {
+	return exportFlashcardsToAnkiTextFile(flashcards, options.DeckName, filename, options.CSV)
+}
+
+// exportOptionsFromFlags reads the export settings shared by every exporter from the command's flags
+func exportOptionsFromFlags(cmd *cobra.Command, deckName string) (ExportOptions, error) {
+	delimiter, _ := cmd.Flags().GetString("delimiter")
+	quote, _ := cmd.Flags().GetString("quote")
+	bom, _ := cmd.Flags().GetBool("bom")
+	noHeader, _ := cmd.Flags().GetBool("no-header")
+
+	r, err := parseDelimiter(delimiter)
+	if err != nil {
+		return ExportOptions{}, err
+	}
+	dialect := CSVDialect{Delimiter: r, Quote: quote, BOM: bom, NoHeader: noHeader}
+	if err := dialect.withDefaults(',').validate(); err != nil {
+		return ExportOptions{}, err
+	}
+	return ExportOptions{DeckName: deckName, CSV: dialect}, nil
+}
@@ -0,0 +1,115 @@
+package url2anki
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestExporterFor tests that exporters are found by extension and by explicit format
+func TestExporterFor(t *testing.T) {
+	tests := []struct {
+		filename string
+		format   string
+		expected string
+		wantErr  bool
+	}{
+		{filename: "deck.json", expected: "json"},
+		{filename: "deck.CSV", expected: "csv"},
+		{filename: "deck.apkg", expected: "apkg"},
+		{filename: "deck.txt", format: "json", expected: "json"},
+		{filename: "deck.xyz", wantErr: true},
+		{filename: "deck.json", format: "yaml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		exporter, err := exporterFor(tt.filename, tt.format)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("exporterFor(%q, %q) expected an error, got %s", tt.filename, tt.format, exporter.Name())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("exporterFor(%q, %q) returned an error: %v", tt.filename, tt.format, err)
+			continue
+		}
+		if exporter.Name() != tt.expected {
+			t.Errorf("exporterFor(%q, %q) = %s, expected %s", tt.filename, tt.format, exporter.Name(), tt.expected)
+		}
+	}
+}
+
+// TestResolveOutputs tests how --output-file and repeated --output flags combine
+func TestResolveOutputs(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("output-file", "o", "./anki_cards.csv", "")
+		cmd.Flags().StringArray("output", nil, "")
+		cmd.Flags().String("format", "", "")
+		cmd.Flags().String("anki-connect", "", "")
+		cmd.Flags().String("collection", "", "")
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		return cmd
+	}
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{args: nil, expected: []string{"./anki_cards.csv"}},
+		{args: []string{"--output", "deck.apkg", "--output", "deck.csv"}, expected: []string{"deck.apkg", "deck.csv"}},
+		{args: []string{"-o", "deck.json", "--output", "deck.apkg"}, expected: []string{"deck.json", "deck.apkg"}},
+		{args: []string{"--anki-connect", "http://127.0.0.1:8765"}, expected: nil},
+		{args: []string{"--collection", "collection.anki2", "-o", "deck.csv"}, expected: []string{"deck.csv"}},
+		{args: []string{"--output", "deck", "--format", "apkg"}, expected: []string{"deck"}},
+	}
+
+	for _, tt := range tests {
+		targets, err := resolveOutputs(newCmd(tt.args...))
+		if err != nil {
+			t.Fatalf("resolveOutputs(%v) returned an error: %v", tt.args, err)
+		}
+		if len(targets) != len(tt.expected) {
+			t.Fatalf("resolveOutputs(%v) = %+v, expected %v", tt.args, targets, tt.expected)
+		}
+		for i, target := range targets {
+			if target.Filename != tt.expected[i] {
+				t.Errorf("resolveOutputs(%v)[%d] = %s, expected %s", tt.args, i, target.Filename, tt.expected[i])
+			}
+		}
+	}
+
+	if _, err := resolveOutputs(newCmd("--output", "deck.apkg", "--format", "nope")); err == nil {
+		t.Errorf("Expected an unknown format to be an error")
+	}
+	if _, err := resolveOutputs(newCmd("--output", "deck.apkg", "--output", "deck.csv", "--format", "csv")); err == nil {
+		t.Errorf("Expected --format with several outputs to be an error")
+	}
+}
+
+// TestValidateOutputsSyncServer tests that --sync-server is refused without --force-full-upload
+func TestValidateOutputsSyncServer(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"--sync-server", "http://127.0.0.1:8080/"}, wantErr: true},
+		{args: []string{"--sync-server", "http://127.0.0.1:8080/", "--force-full-upload"}, wantErr: false},
+	} {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("output-file", "o", "./anki_cards.csv", "")
+		cmd.Flags().StringArray("output", nil, "")
+		cmd.Flags().String("format", "", "")
+		cmd.Flags().String("sync-server", "", "")
+		cmd.Flags().Bool("force-full-upload", false, "")
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		if err := ValidateOutputs(cmd, nil); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateOutputs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
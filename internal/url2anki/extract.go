@@ -0,0 +1,358 @@
+package url2anki
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// hiddenSelector matches elements a reader never sees, left out of scraped text unless turned off
+	hiddenSelector = `[hidden], [aria-hidden=true], [style*="display:none"], [style*="display: none"], ` +
+		`[style*="visibility:hidden"], [style*="visibility: hidden"], .sr-only, .visually-hidden, .screen-reader-text`
+	// snippetLength bounds how much of a skipped element's text is shown in reports
+	snippetLength = 60
+	// selectorsMode pairs the elements matched by the question and answer selectors
+	selectorsMode = "selectors"
+)
+
+// extractors maps each --mode to the strategy that picks flashcards out of a page
+var extractors = map[string]func(*goquery.Document, extractOptions) (extraction, error){
+	selectorsMode:  extractSelectors,
+	"dl":           extractDefinitionLists,
+	"details":      extractDetails,
+	"table":        extractTables,
+	"sections":     extractSections,
+	"inline-terms": extractInlineTerms,
+}
+
+// extractOptions describes how flashcards are picked out of a page
+type extractOptions struct {
+	Mode             string
+	ItemSelector     string
+	QuestionSelector string
+	AnswerSelector   string
+	GUIDSelector     string
+	// Tolerant pairs each question with the next answer in document order instead of by index
+	Tolerant bool
+
+	// TableSelector, QuestionColumn and AnswerColumn pick the tables and columns read in table mode.
+	// Columns are 1-based numbers or header text.
+	TableSelector  string
+	QuestionColumn string
+	AnswerColumn   string
+	// ExtraColumns are appended to the answer and TagColumns become tags in table mode
+	ExtraColumns []string
+	TagColumns   []string
+
+	// FieldSeparator joins the values of a selector's "&&"-separated parts
+	FieldSeparator string
+
+	// Exclude matches the elements left out of every question and answer before its text is taken
+	Exclude string
+
+	// MainContent isolates the page's main article content before any mode runs
+	MainContent bool
+
+	// HeadingSelector picks the headings turned into questions in sections mode (EX: h2,h3)
+	HeadingSelector string
+
+	// LinkSelector picks the links whose text becomes each question, and DetailAnswerSelector reads
+	// each answer from the page the link points to, fetching DetailConcurrency pages at a time
+	LinkSelector         string
+	DetailAnswerSelector string
+	DetailConcurrency    int
+}
+
+// skippedItem is an element left out of the deck because its question or answer was not found
+type skippedItem struct {
+	// Kind is what was skipped: an item container, a question or an answer
+	Kind    string
+	Index   int
+	Path    string
+	Missing string
+	Snippet string
+	// Err is why the missing part could not be read (EX: a detail page that failed to fetch)
+	Err error
+}
+
+// extraction is the result of scraping a page: the flashcards, the GUID key of each flashcard
+// (nil when no GUID selector was given) and the containers that were skipped
+type extraction struct {
+	Flashcards []Flashcard
+	Keys       []string
+	Skipped    []skippedItem
+}
+
+// extractOptionsFromFlags builds the extraction options from the command line flags
+func extractOptionsFromFlags(cmd *cobra.Command) extractOptions {
+	mode, _ := cmd.Flags().GetString("mode")
+	itemSelector, _ := cmd.Flags().GetString("item-selector")
+	questionSelector, _ := cmd.Flags().GetString("question-selector")
+	answerSelector, _ := cmd.Flags().GetString("answer-selector")
+	guidSelector, _ := cmd.Flags().GetString("guid-selector")
+	tolerant, _ := cmd.Flags().GetBool("tolerant")
+	tableSelector, _ := cmd.Flags().GetString("table-selector")
+	questionColumn, _ := cmd.Flags().GetString("question-col")
+	answerColumn, _ := cmd.Flags().GetString("answer-col")
+	extraColumns, _ := cmd.Flags().GetStringArray("extra-col")
+	tagColumns, _ := cmd.Flags().GetStringArray("tag-col")
+	headingSelector, _ := cmd.Flags().GetString("heading")
+	mainContent, _ := cmd.Flags().GetBool("main-content")
+	fieldSeparator, _ := cmd.Flags().GetString("field-separator")
+	excludes, _ := cmd.Flags().GetStringArray("exclude")
+	keepHidden, _ := cmd.Flags().GetBool("keep-hidden")
+	linkSelector, _ := cmd.Flags().GetString("link-selector")
+	detailAnswerSelector, _ := cmd.Flags().GetString("detail-answer-selector")
+	detailConcurrency, _ := cmd.Flags().GetInt("detail-concurrency")
+	if !keepHidden {
+		excludes = append([]string{hiddenSelector}, excludes...)
+	}
+	if mode == "" {
+		mode = selectorsMode
+	}
+	return extractOptions{
+		Mode:             mode,
+		ItemSelector:     itemSelector,
+		QuestionSelector: questionSelector,
+		AnswerSelector:   answerSelector,
+		GUIDSelector:     guidSelector,
+		Tolerant:         tolerant,
+		TableSelector:    tableSelector,
+		QuestionColumn:   questionColumn,
+		AnswerColumn:     answerColumn,
+		ExtraColumns:     extraColumns,
+		TagColumns:       tagColumns,
+		HeadingSelector:  headingSelector,
+		MainContent:      mainContent,
+		FieldSeparator:   fieldSeparator,
+		Exclude:          strings.Join(excludes, ", "),
+
+		LinkSelector:         linkSelector,
+		DetailAnswerSelector: detailAnswerSelector,
+		DetailConcurrency:    detailConcurrency,
+	}
+}
+
+// validate checks that the mode exists and has the selectors it needs
+func (o extractOptions) validate() error {
+	if _, ok := extractors[o.Mode]; !ok {
+		return fmt.Errorf("unknown mode %q (supported: %s)", o.Mode, strings.Join(extractorNames(), ", "))
+	}
+	if o.LinkSelector != "" || o.DetailAnswerSelector != "" {
+		if err := o.validateLinks(); err != nil {
+			return err
+		}
+	} else if o.Mode == selectorsMode && (o.QuestionSelector == "" || o.AnswerSelector == "") {
+		return errors.New("--question-selector and --answer-selector are required unless --mode picks a built-in extractor or --link-selector reads answers from linked pages")
+	}
+	_, _, _, err := o.fieldSelectors()
+	return err
+}
+
+// fieldSelectors parses the question, answer and GUID selectors
+func (o extractOptions) fieldSelectors() (question, answer, guid fieldSelector, err error) {
+	if question, err = parseFieldSelector(o.QuestionSelector, o.FieldSeparator); err != nil {
+		return
+	}
+	if answer, err = parseFieldSelector(o.AnswerSelector, o.FieldSeparator); err != nil {
+		return
+	}
+	if guid, err = parseFieldSelector(o.GUIDSelector, o.FieldSeparator); err != nil {
+		return
+	}
+	if o.ItemSelector != "" {
+		_, err = parseSelectorPart(o.ItemSelector)
+	}
+	return
+}
+
+// ValidateScrape fails before any scraping happens when no valid URL or sitemap is given, the sitemap
+// filters are invalid, or the extraction mode or its selectors are invalid
+func ValidateScrape(cmd *cobra.Command, args []string) error {
+	if _, err := sourceURLs(cmd, args); err != nil {
+		return err
+	}
+	if _, err := sitemapFilterFromFlags(cmd); err != nil {
+		return err
+	}
+	return extractOptionsFromFlags(cmd).validate()
+}
+
+// extractorNames returns the registered mode names in sorted order
+func extractorNames() []string {
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extract scrapes the flashcards from doc with the strategy picked by the options' mode
+func extract(doc *goquery.Document, options extractOptions) (extraction, error) {
+	if options.Mode == "" {
+		options.Mode = selectorsMode
+	}
+	if err := options.validate(); err != nil {
+		return extraction{}, err
+	}
+	return extractors[options.Mode](prepareDocument(doc, options), options)
+}
+
+// prepareDocument narrows doc down to the content the options ask for before any mode runs
+func prepareDocument(doc *goquery.Document, options extractOptions) *goquery.Document {
+	if options.MainContent {
+		doc = isolateMainContent(doc)
+	}
+	return doc
+}
+
+// extractSelectors pairs questions and answers inside each item container when an item selector
+// is given and across the whole page otherwise
+func extractSelectors(doc *goquery.Document, options extractOptions) (extraction, error) {
+	question, answer, guid, err := options.fieldSelectors()
+	if err != nil {
+		return extraction{}, err
+	}
+	if options.ItemSelector != "" {
+		item, err := parseSelectorPart(options.ItemSelector)
+		if err != nil {
+			return extraction{}, err
+		}
+		return extractItems(doc, item, question, answer, guid, options.Exclude), nil
+	}
+	if options.Tolerant {
+		return extractTolerant(doc, question, answer, guid, options.Exclude)
+	}
+
+	flashcards, err := extractFlashcards(doc, question, answer, options.Exclude)
+	if err != nil {
+		return extraction{}, err
+	}
+	var keys []string
+	if !guid.empty() {
+		if keys, err = extractKeys(doc, guid); err != nil {
+			return extraction{}, err
+		}
+		if len(keys) != len(flashcards) {
+			return extraction{}, errors.New("the number of GUID keys and flashcards do not match")
+		}
+	}
+	return extraction{Flashcards: flashcards, Keys: keys}, nil
+}
+
+// extractItems builds one flashcard per element matched by the item selector, evaluating the question,
+// answer and GUID selectors inside it. Items without a question or answer are skipped and reported.
+func extractItems(doc *goquery.Document, itemSelector selectorPart, questionField, answerField, guidField fieldSelector, exclude string) extraction {
+	var result extraction
+	itemSelector.find(doc.Selection).Each(func(i int, item *goquery.Selection) {
+		question := questionField.within(item, exclude, true)
+		answer := answerField.within(item, exclude, false)
+
+		if missing := missingFields(Flashcard{Question: question, Answer: answer}); missing != "" {
+			result.Skipped = append(result.Skipped, skippedItem{
+				Kind:    "item",
+				Index:   i + 1,
+				Path:    domPath(item.Get(0)),
+				Missing: missing,
+				Snippet: snippet(item.Text()),
+			})
+			return
+		}
+
+		result.Flashcards = append(result.Flashcards, Flashcard{Question: question, Answer: answer})
+		if !guidField.empty() {
+			result.Keys = append(result.Keys, guidField.within(item, "", true))
+		}
+	})
+	return result
+}
+
+// extractTolerant pairs each question with the next answer in document order, keeping the GUID key
+// of every question that found an answer
+func extractTolerant(doc *goquery.Document, question, answer, guid fieldSelector, exclude string) (extraction, error) {
+	questions, err := question.all(doc.Selection, exclude)
+	if err != nil {
+		return extraction{}, err
+	}
+	answers, err := answer.all(doc.Selection, exclude)
+	if err != nil {
+		return extraction{}, err
+	}
+	flashcards, kept, skipped := pairTolerant(questions, answers)
+	result := extraction{Flashcards: flashcards, Skipped: skipped}
+	if !guid.empty() {
+		keys, err := extractKeys(doc, guid)
+		if err != nil {
+			return extraction{}, err
+		}
+		if len(keys) != len(questions) {
+			return extraction{}, errors.New("the number of GUID keys and questions do not match")
+		}
+		for _, index := range kept {
+			result.Keys = append(result.Keys, keys[index-1])
+		}
+	}
+	return result, nil
+}
+
+// textOf returns the text of s without the descendants matching exclude
+func textOf(s *goquery.Selection, exclude string) string {
+	if exclude == "" {
+		return s.Text()
+	}
+	clone := s.Clone()
+	clone.Find(exclude).Remove()
+	return clone.Text()
+}
+
+// missingFields names the empty fields of a scraped flashcard, or returns "" when both are present
+func missingFields(flashcard Flashcard) string {
+	var missing []string
+	if flashcard.Question == "" {
+		missing = append(missing, "question")
+	}
+	if flashcard.Answer == "" {
+		missing = append(missing, "answer")
+	}
+	return strings.Join(missing, " and ")
+}
+
+// cleanText removes newlines from scraped text and trims the surrounding whitespace. Only page-wide selector
+// pairing still uses it, so decks scraped that way keep the text, and GUIDs, of earlier runs.
+func cleanText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "")
+	text = strings.ReplaceAll(text, "\n", "")
+	return strings.TrimSpace(text)
+}
+
+// collapseWhitespace turns every run of whitespace, line breaks included, into a single space and trims the ends
+func collapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// snippet collapses the whitespace in text and shortens it for display
+func snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > snippetLength {
+		return string(runes[:snippetLength]) + "…"
+	}
+	return text
+}
+
+// printSkippedItems reports the elements that were left out of the deck
+func printSkippedItems(w io.Writer, skipped []skippedItem) {
+	for _, item := range skipped {
+		reason := "no " + item.Missing + " found"
+		if item.Err != nil {
+			reason += ": " + item.Err.Error()
+		}
+		fmt.Fprintf(w, "Skipped %s %d (%s) at %s: %q\n", item.Kind, item.Index, reason, item.Path, item.Snippet)
+	}
+}
@@ -0,0 +1,90 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestExtractItems tests that items are paired inside their container and incomplete items are skipped
+func TestExtractItems(t *testing.T) {
+	html := `<html><body>
+		<div class="entry"><span class="id">pod</span><h3>Pod</h3><p>The smallest deployable unit</p></div>
+		<div class="entry"><span class="id">node</span><h3>Node</h3></div>
+		<div class="entry"><span class="id">svc</span><h3>Service</h3><p>An abstract way</p><p>to expose an app</p></div>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{
+		ItemSelector:     "div.entry",
+		QuestionSelector: "h3",
+		AnswerSelector:   "p",
+		GUIDSelector:     "span.id",
+	})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "Pod", Answer: "The smallest deployable unit"},
+		{Question: "Service", Answer: "An abstract way to expose an app"},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+	if expectedKeys := []string{"pod", "svc"}; !reflect.DeepEqual(result.Keys, expectedKeys) {
+		t.Errorf("Expected keys %v, got %v", expectedKeys, result.Keys)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Index != 2 || result.Skipped[0].Missing != "answer" {
+		t.Errorf("Expected item 2 to be skipped for its missing answer, got %+v", result.Skipped)
+	}
+}
+
+// TestExtractMismatchedGUIDKeys tests that page-wide pairing rejects GUID keys that do not line up
+func TestExtractMismatchedGUIDKeys(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<dl><dt>Pod</dt><dd>Unit</dd><dt>Node</dt><dd>Machine</dd></dl><a>pod</a>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	if _, err := extract(doc, extractOptions{QuestionSelector: "dt", AnswerSelector: "dd", GUIDSelector: "a"}); err == nil {
+		t.Error("Expected mismatched GUID keys to be rejected")
+	}
+}
+
+// TestExtractExcludes tests that excluded and hidden elements are left out of questions and answers
+func TestExtractExcludes(t *testing.T) {
+	html := `<html><body><dl>
+		<dt>Pod<a class="anchor" href="#pod">¶</a></dt>
+		<dd>The smallest deployable unit<sup class="reference">[1]</sup><span aria-hidden="true">⚓</span> <a class="edit">edit</a></dd>
+	</dl></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	expected := []Flashcard{{Question: "Pod", Answer: "The smallest deployable unit"}}
+	for _, mode := range []string{selectorsMode, "dl"} {
+		result, err := extract(doc, extractOptions{
+			Mode:             mode,
+			QuestionSelector: "dt",
+			AnswerSelector:   "dd",
+			Exclude:          excluding(hiddenSelector, "a.anchor", "sup.reference", "a.edit"),
+		})
+		if err != nil {
+			t.Fatalf("extract returned an error in %s mode: %v", mode, err)
+		}
+		if !reflect.DeepEqual(result.Flashcards, expected) {
+			t.Errorf("Expected %+v in %s mode, got %+v", expected, mode, result.Flashcards)
+		}
+	}
+
+	// The elements stay in the page itself
+	if doc.Find("a.edit").Length() != 1 {
+		t.Error("Expected the document to be left untouched")
+	}
+}
@@ -0,0 +1,88 @@
+package url2anki
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// guidAlphabet is alphanumeric so GUIDs are also safe inside CSV cells, tags and Anki search queries
+const guidAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// guidFor derives a deterministic Anki note GUID from the given values
+func guidFor(values ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+	n := binary.BigEndian.Uint64(sum[:8])
+	if n == 0 {
+		return string(guidAlphabet[0])
+	}
+
+	var guid []byte
+	for n > 0 {
+		guid = append([]byte{guidAlphabet[n%uint64(len(guidAlphabet))]}, guid...)
+		n /= uint64(len(guidAlphabet))
+	}
+	return string(guid)
+}
+
+// assignGUIDs gives every flashcard a GUID derived from the source URL plus its key.
+// The key is the matching entry of keys when given, otherwise the card's normalized question.
+// When a key repeats on the page, later cards also hash their occurrence number so each keeps its own GUID.
+func assignGUIDs(flashcards []Flashcard, sourceURL string, keys []string) {
+	source := normalizeSourceURL(sourceURL)
+	occurrences := map[string]int{}
+	for i := range flashcards {
+		key := normalizeKey(flashcards[i].Question)
+		if i < len(keys) && normalizeKey(keys[i]) != "" {
+			key = normalizeKey(keys[i])
+		}
+		occurrences[key]++
+		if n := occurrences[key]; n > 1 {
+			flashcards[i].GUID = guidFor(source, key, strconv.Itoa(n))
+		} else {
+			flashcards[i].GUID = guidFor(source, key)
+		}
+	}
+}
+
+// extractKeys returns the value of each match of the key selector, to be paired with flashcards by index
+func extractKeys(doc *goquery.Document, key fieldSelector) ([]string, error) {
+	matches, err := key.all(doc.Selection, "")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(matches))
+	for i, match := range matches {
+		keys[i] = match.Text
+	}
+	return keys, nil
+}
+
+// normalizeKey lowercases s, strips HTML and collapses whitespace so cosmetic edits keep the same GUID
+func normalizeKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(stripHTML(s)), " "))
+}
+
+// normalizeSourceURL drops the fragment and trailing slash so equivalent links share GUIDs
+func normalizeSourceURL(sourceURL string) string {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return sourceURL
+	}
+	u.Fragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
+
+// noteGUID returns the flashcard's GUID, deriving one from its question for cards scraped without one
+// so that a changed answer still updates the same note
+func noteGUID(flashcard Flashcard) string {
+	if flashcard.GUID != "" {
+		return flashcard.GUID
+	}
+	return guidFor(flashcard.Question)
+}
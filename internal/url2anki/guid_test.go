@@ -0,0 +1,83 @@
+package url2anki
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestGUIDFor tests that guidFor is deterministic and sensitive to its input
+func TestGUIDFor(t *testing.T) {
+	if guidFor("a", "b") != guidFor("a", "b") {
+		t.Errorf("Expected guidFor to be deterministic")
+	}
+	if guidFor("a", "b") == guidFor("a", "c") {
+		t.Errorf("Expected different inputs to produce different GUIDs")
+	}
+}
+
+// TestNoteGUID tests that cards without a GUID fall back to one derived from their question alone
+func TestNoteGUID(t *testing.T) {
+	if noteGUID(Flashcard{Question: "Pod", Answer: "Old"}) != noteGUID(Flashcard{Question: "Pod", Answer: "New"}) {
+		t.Errorf("Expected a changed answer to keep the fallback GUID")
+	}
+	if guid := noteGUID(Flashcard{Question: "Pod", GUID: "abc"}); guid != "abc" {
+		t.Errorf("Expected an assigned GUID to be kept, got %q", guid)
+	}
+}
+
+// TestAssignGUIDs tests the assignGUIDs function
+func TestAssignGUIDs(t *testing.T) {
+	first := []Flashcard{{Question: "Pod", Answer: "Answer 1"}}
+	rescraped := []Flashcard{{Question: "  pod\n", Answer: "Changed answer"}}
+	otherPage := []Flashcard{{Question: "Pod", Answer: "Answer 1"}}
+
+	assignGUIDs(first, "https://example.com/glossary/#top", nil)
+	assignGUIDs(rescraped, "https://example.com/glossary", nil)
+	assignGUIDs(otherPage, "https://example.com/other", nil)
+
+	if first[0].GUID == "" {
+		t.Fatalf("Expected a GUID to be assigned")
+	}
+	if first[0].GUID != rescraped[0].GUID {
+		t.Errorf("Expected a re-scrape with a cosmetically different question to keep its GUID")
+	}
+	if first[0].GUID == otherPage[0].GUID {
+		t.Errorf("Expected cards from different pages to get different GUIDs")
+	}
+
+	// An explicit key survives the question being reworded
+	keyed := []Flashcard{{Question: "Pod", Answer: "Answer 1"}}
+	reworded := []Flashcard{{Question: "Pods", Answer: "Answer 1"}}
+	assignGUIDs(keyed, "https://example.com/glossary", []string{"term-pod"})
+	assignGUIDs(reworded, "https://example.com/glossary", []string{"term-pod"})
+	if keyed[0].GUID != reworded[0].GUID {
+		t.Errorf("Expected cards with the same key to share a GUID")
+	}
+
+	// Questions that only differ in case or whitespace still get one GUID each, the first keeping its own
+	repeated := []Flashcard{{Question: "Pod"}, {Question: "pod"}, {Question: " POD "}}
+	assignGUIDs(repeated, "https://example.com/glossary", nil)
+	if repeated[0].GUID != first[0].GUID {
+		t.Errorf("Expected the first of repeated questions to keep the GUID it gets alone")
+	}
+	if repeated[0].GUID == repeated[1].GUID || repeated[1].GUID == repeated[2].GUID || repeated[0].GUID == repeated[2].GUID {
+		t.Errorf("Expected repeated questions to get distinct GUIDs, got %q, %q and %q", repeated[0].GUID, repeated[1].GUID, repeated[2].GUID)
+	}
+}
+
+// TestUpsertNoteByGUID tests that a collection note is found by GUID even when its question changed
+func TestUpsertNoteByGUID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	original := []Flashcard{{Question: "Pod", Answer: "Answer 1", GUID: "guid1"}}
+	if err := writeDeckCollection(original, "Glossary", path); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	report, err := writeFlashcardsToCollection([]Flashcard{{Question: "Pods", Answer: "Answer 1", GUID: "guid1"}}, "Glossary", path)
+	if err != nil {
+		t.Fatalf("writeFlashcardsToCollection returned an error: %v", err)
+	}
+	if expected := (collectionReport{Updated: 1}); report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, report)
+	}
+}
@@ -0,0 +1,105 @@
+package url2anki
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// inlineTermSelector matches abbreviations, definitions and glossary tooltips written inline in prose
+	inlineTermSelector = "abbr[title], dfn, span[title], [data-tooltip], [data-tippy-content]"
+	// sentenceBlockSelector matches the elements a definition's surrounding sentence is looked for in
+	sentenceBlockSelector = "p, li, dd, dt, td, th, blockquote, figcaption, caption, div, section, article, body"
+	// termStart and termEnd mark where a <dfn> sits in its block's text while the sentence is cut out
+	termStart = "\x01"
+	termEnd   = "\x02"
+	// termMarkerAttr flags the <dfn> whose sentence is being cut out of a cloned block
+	termMarkerAttr = "data-url2anki-term"
+	// termBlank replaces the defined term in its sentence so the answer doesn't give the question away
+	termBlank = "[...]"
+)
+
+// extractInlineTerms builds flashcards from terms defined inline: every <abbr title> and glossary tooltip
+// becomes an abbreviation → expansion card and every <dfn> a term → surrounding sentence card, with the term blanked out of the sentence. A term
+// appearing again with the same answer is only kept once.
+func extractInlineTerms(doc *goquery.Document, options extractOptions) (extraction, error) {
+	var result extraction
+	seen := map[string]bool{}
+
+	doc.Find(inlineTermSelector).Each(func(i int, term *goquery.Selection) {
+		var flashcard Flashcard
+		if goquery.NodeName(term) == "dfn" {
+			// A <dfn title> names the defined term explicitly
+			flashcard.Question = collapseWhitespace(term.AttrOr("title", ""))
+			if flashcard.Question == "" {
+				flashcard.Question = collapseWhitespace(textOf(term, options.Exclude))
+			}
+			flashcard.Answer = surroundingSentence(term, options.Exclude)
+		} else {
+			flashcard.Question = collapseWhitespace(textOf(term, options.Exclude))
+			for _, attr := range []string{"title", "data-tooltip", "data-tippy-content"} {
+				if value := collapseWhitespace(term.AttrOr(attr, "")); value != "" {
+					flashcard.Answer = value
+					break
+				}
+			}
+		}
+
+		if missing := missingFields(flashcard); missing != "" {
+			result.Skipped = append(result.Skipped, skippedItem{
+				Kind:    goquery.NodeName(term),
+				Index:   i + 1,
+				Path:    domPath(term.Get(0)),
+				Missing: missing,
+				Snippet: snippet(term.Text()),
+			})
+			return
+		}
+
+		key := normalizeKey(flashcard.Question) + "\x00" + normalizeKey(flashcard.Answer)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		result.Flashcards = append(result.Flashcards, flashcard)
+	})
+	return result, nil
+}
+
+// surroundingSentence returns the sentence of the enclosing block that holds term, with term replaced by
+// termBlank and without the descendants matching exclude
+func surroundingSentence(term *goquery.Selection, exclude string) string {
+	block := term.Parent().Closest(sentenceBlockSelector)
+	if block.Length() == 0 {
+		return ""
+	}
+
+	// Mark the term in a copy of its block so it can be found again once the block is flattened to text
+	term.SetAttr(termMarkerAttr, "")
+	clone := block.Clone()
+	term.RemoveAttr(termMarkerAttr)
+	if exclude != "" {
+		clone.Find(exclude).Not("[" + termMarkerAttr + "]").Remove()
+	}
+	marked := clone.Find("[" + termMarkerAttr + "]")
+	marked.BeforeHtml(termStart)
+	marked.AfterHtml(termEnd)
+
+	text := strings.Join(strings.Fields(clone.Text()), " ")
+	start := strings.Index(text, termStart)
+	end := strings.Index(text, termEnd)
+	if start < 0 || end < start {
+		return ""
+	}
+
+	// The sentence runs from the last sentence end before the term to the first one after it
+	if i := strings.LastIndexAny(text[:start], ".!?"); i >= 0 {
+		text, end, start = text[i+1:], end-(i+1), start-(i+1)
+	}
+	if i := strings.IndexAny(text[end:], ".!?"); i >= 0 {
+		text = text[:end+i+1]
+	}
+	// Blank the term only once the sentence is cut, since the blank itself holds dots
+	return collapseWhitespace(text[:start] + termBlank + text[end+len(termEnd):])
+}
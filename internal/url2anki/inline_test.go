@@ -0,0 +1,41 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestExtractInlineTerms tests abbreviation, definition and tooltip cards and that repeats are dropped
+func TestExtractInlineTerms(t *testing.T) {
+	html := `<html><body>
+		<p>Kubernetes runs containers. A <dfn>Pod</dfn> is the smallest deployable unit<sup aria-hidden="true">1</sup>! Pods share a network.</p>
+		<p>Every <abbr title="Custom Resource Definition">CRD</abbr> extends the API, and a second <abbr title="Custom Resource Definition">CRD</abbr> is no new card.</p>
+		<p>The <span class="tooltip" data-tooltip="Role-Based Access Control">RBAC</span> rules and <abbr>HTML</abbr> without a title.</p>
+		<p><dfn title="Node">Nodes</dfn> are the machines.</p>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{Mode: "inline-terms", Exclude: hiddenSelector})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "Pod", Answer: "A [...] is the smallest deployable unit!"},
+		{Question: "CRD", Answer: "Custom Resource Definition"},
+		{Question: "RBAC", Answer: "Role-Based Access Control"},
+		{Question: "Node", Answer: "[...] are the machines."},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+	if doc.Find("["+termMarkerAttr+"]").Length() != 0 {
+		t.Error("Expected the document to be left untouched")
+	}
+}
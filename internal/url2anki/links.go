@@ -0,0 +1,176 @@
+package url2anki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// defaultDetailConcurrency is how many detail pages are fetched at once when no limit is given
+const defaultDetailConcurrency = 4
+
+// detailLink is a link on an index page: its text is a question and the page it points to holds the answer
+type detailLink struct {
+	// Index is the 1-based position of the link among the elements matched by the link selector
+	Index    int
+	Question string
+	URL      string
+	Node     *html.Node
+}
+
+// detailPage is what was read from the page a detail link points to
+type detailPage struct {
+	Answer string
+	Key    string
+	Err    error
+}
+
+// validateLinks checks the list-to-detail selectors, which must be given together and only in selectors mode
+func (o extractOptions) validateLinks() error {
+	if o.LinkSelector == "" || o.DetailAnswerSelector == "" {
+		return errors.New("--link-selector and --detail-answer-selector must be given together")
+	}
+	if o.Mode != selectorsMode {
+		return errors.New("--link-selector cannot be combined with --mode " + o.Mode)
+	}
+	if o.DetailConcurrency < 0 {
+		return errors.New("--detail-concurrency must not be negative")
+	}
+	if _, err := parseSelectorPart(o.LinkSelector); err != nil {
+		return err
+	}
+	_, err := parseFieldSelector(o.DetailAnswerSelector, o.FieldSeparator)
+	return err
+}
+
+// extractLinkedPages builds one flashcard per link matched by the link selector on the index page at
+// pageURL. The link's text is the question and the detail answer selector, evaluated on the page the link
+// points to, gives the answer. Detail pages are fetched concurrently, at most DetailConcurrency at a time,
+// until ctx is done. Links whose page cannot be fetched or has no answer are skipped and reported.
+func extractLinkedPages(ctx context.Context, doc *goquery.Document, pageURL string, options extractOptions) (extraction, error) {
+	if err := options.validate(); err != nil {
+		return extraction{}, err
+	}
+	linkSelector, err := parseSelectorPart(options.LinkSelector)
+	if err != nil {
+		return extraction{}, err
+	}
+	answer, err := parseFieldSelector(options.DetailAnswerSelector, options.FieldSeparator)
+	if err != nil {
+		return extraction{}, err
+	}
+	guid, err := parseFieldSelector(options.GUIDSelector, options.FieldSeparator)
+	if err != nil {
+		return extraction{}, err
+	}
+
+	var result extraction
+	links, skipped, err := detailLinks(prepareDocument(doc, options), pageURL, linkSelector, options.Exclude)
+	if err != nil {
+		return extraction{}, err
+	}
+	result.Skipped = skipped
+
+	concurrency := options.DetailConcurrency
+	if concurrency < 1 {
+		concurrency = defaultDetailConcurrency
+	}
+	details := make([]detailPage, len(links))
+	limit := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-limit }()
+			details[i] = readDetailPage(ctx, link.URL, answer, guid, options)
+		}()
+	}
+	wg.Wait()
+
+	for i, link := range links {
+		detail := details[i]
+		if detail.Err != nil || detail.Answer == "" {
+			missing := "answer"
+			if detail.Err != nil {
+				missing = "detail page"
+			}
+			result.Skipped = append(result.Skipped, skippedItem{
+				Kind:    "link",
+				Index:   link.Index,
+				Path:    domPath(link.Node),
+				Missing: missing,
+				Snippet: snippet(link.URL),
+				Err:     detail.Err,
+			})
+			continue
+		}
+		result.Flashcards = append(result.Flashcards, Flashcard{Question: link.Question, Answer: detail.Answer})
+		result.Keys = append(result.Keys, detail.Key)
+	}
+	return result, nil
+}
+
+// detailLinks returns the links matched by selector in doc, one per distinct target page, with the
+// text (or @attr) of each as its question. Matched elements without an href take the first link inside
+// them; the ones with no link at all or no text are skipped and reported. It fails when pageURL cannot
+// be parsed to resolve the links against.
+func detailLinks(doc *goquery.Document, pageURL string, selector selectorPart, exclude string) ([]detailLink, []skippedItem, error) {
+	base, err := documentBase(doc, pageURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving the links of %q: %w", pageURL, err)
+	}
+
+	var links []detailLink
+	var skipped []skippedItem
+	seen := map[string]bool{}
+	selector.find(doc.Selection).Each(func(i int, s *goquery.Selection) {
+		question := collapseWhitespace(selector.value(s, exclude))
+		href, ok := s.Attr("href")
+		if !ok {
+			href, ok = s.Find("a[href]").First().Attr("href")
+		}
+		target, err := base.Parse(href)
+		if !ok || err != nil || (target.Scheme != "http" && target.Scheme != "https") || question == "" {
+			missing := "link"
+			if question == "" {
+				missing = "question"
+			}
+			skipped = append(skipped, skippedItem{
+				Kind:    "link",
+				Index:   i + 1,
+				Path:    domPath(s.Get(0)),
+				Missing: missing,
+				Snippet: snippet(s.Text()),
+			})
+			return
+		}
+		target.Fragment = ""
+		if seen[target.String()] {
+			return
+		}
+		seen[target.String()] = true
+		links = append(links, detailLink{Index: i + 1, Question: question, URL: target.String(), Node: s.Get(0)})
+	})
+	return links, skipped, nil
+}
+
+// readDetailPage fetches the page at pageURL, giving up when ctx is done, and reads the answer from it,
+// keyed by the GUID selector when one is given and by the page's URL otherwise
+func readDetailPage(ctx context.Context, pageURL string, answer, guid fieldSelector, options extractOptions) detailPage {
+	doc, err := fetchDocumentContext(ctx, pageURL)
+	if err != nil {
+		return detailPage{Err: err}
+	}
+	root := prepareDocument(doc, options).Selection
+	detail := detailPage{Answer: answer.within(root, options.Exclude, false), Key: pageURL}
+	if !guid.empty() {
+		detail.Key = guid.within(root, "", true)
+	}
+	return detail
+}
@@ -0,0 +1,122 @@
+package url2anki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestExtractLinkedPages tests that each index link becomes a card answered from its detail page, that
+// detail pages are fetched at most DetailConcurrency at a time, and that broken links are reported
+func TestExtractLinkedPages(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/glossary/":
+			fmt.Fprint(w, `<ul class="terms">
+				<li><a href="api">API</a></li>
+				<li><a href="boot#usage">Boot</a></li>
+				<li><a href="/glossary/boot">Boot again</a></li>
+				<li><a href="cache">Cache</a></li>
+				<li><a href="dns">DNS</a></li>
+				<li><a href="missing">Missing</a></li>
+				<li><a href="empty">Empty</a></li>
+			</ul>`)
+			return
+		case "/glossary/missing":
+			http.NotFound(w, r)
+			return
+		case "/glossary/empty":
+			fmt.Fprint(w, `<article></article>`)
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		term := strings.TrimPrefix(r.URL.Path, "/glossary/")
+		fmt.Fprintf(w, `<article><p>Definition of %s.</p><p>More detail.</p></article>`, term)
+	}))
+	defer server.Close()
+
+	doc, err := fetchDocument(server.URL + "/glossary/")
+	if err != nil {
+		t.Fatalf("fetchDocument returned an error: %v", err)
+	}
+	options := extractOptions{
+		Mode:                 selectorsMode,
+		LinkSelector:         "ul.terms a",
+		DetailAnswerSelector: "article p:first-of-type",
+		DetailConcurrency:    2,
+	}
+	result, err := extractLinkedPages(context.Background(), doc, server.URL+"/glossary/", options)
+	if err != nil {
+		t.Fatalf("extractLinkedPages returned an error: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "API", Answer: "Definition of api."},
+		{Question: "Boot", Answer: "Definition of boot."},
+		{Question: "Cache", Answer: "Definition of cache."},
+		{Question: "DNS", Answer: "Definition of dns."},
+	}
+	if len(result.Flashcards) != len(expected) {
+		t.Fatalf("Expected %d flashcards, got %+v", len(expected), result.Flashcards)
+	}
+	for i, card := range result.Flashcards {
+		if card.Question != expected[i].Question || card.Answer != expected[i].Answer {
+			t.Errorf("Expected flashcard %+v, got %+v", expected[i], card)
+		}
+	}
+	if result.Keys[1] != server.URL+"/glossary/boot" {
+		t.Errorf("Expected cards to be keyed by their detail page, got %q", result.Keys[1])
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 detail pages in flight, got %d", maxInFlight)
+	}
+
+	var skipped []string
+	for _, item := range result.Skipped {
+		skipped = append(skipped, fmt.Sprintf("%d:%s", item.Index, item.Missing))
+	}
+	if strings.Join(skipped, " ") != "6:detail page 7:answer" {
+		t.Errorf("Expected the missing and empty detail pages to be reported, got %v", skipped)
+	}
+	var report strings.Builder
+	printSkippedItems(&report, result.Skipped)
+	if !strings.Contains(report.String(), "(no detail page found: failed to fetch the URL: 404 Not Found)") {
+		t.Errorf("Expected the detail page's error to be reported, got %q", report.String())
+	}
+
+	if _, err := extractLinkedPages(context.Background(), doc, "http://[::1", options); err == nil {
+		t.Error("Expected an index page URL the links cannot be resolved against to be reported")
+	}
+}
+
+// TestValidateLinks tests that the list-to-detail selectors are given together and only in selectors mode
+func TestValidateLinks(t *testing.T) {
+	valid := extractOptions{Mode: selectorsMode, LinkSelector: "ul.terms a", DetailAnswerSelector: "article p"}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Expected link selectors without question and answer selectors to be valid, got %v", err)
+	}
+	for _, options := range []extractOptions{
+		{Mode: selectorsMode, LinkSelector: "ul.terms a"},
+		{Mode: "dl", LinkSelector: "ul.terms a", DetailAnswerSelector: "article p"},
+	} {
+		if err := options.validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", options)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package url2anki
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadFlashcardsFromFile reads flashcards back from a file written by one of the JSON or CSV exporters
+func loadFlashcardsFromFile(filename string) ([]Flashcard, error) {
+	switch {
+	case strings.HasSuffix(filename, ".json"):
+		return loadFlashcardsFromJSONFile(filename)
+	case strings.HasSuffix(filename, ".csv"):
+		return loadFlashcardsFromCSVFile(filename)
+	default:
+		return nil, fmt.Errorf("cannot read flashcards from %s: expected a .json or .csv file", filename)
+	}
+}
+
+// loadFlashcardsFromJSONFile reads flashcards from a file written by exportFlashcardsToJSONFile
+func loadFlashcardsFromJSONFile(filename string) ([]Flashcard, error) {
+	data, err := os.ReadFile(filename) // #nosec G304 -- filename from user CLI arg, expected
+	if err != nil {
+		return nil, err
+	}
+	var flashcards []Flashcard
+	if err := json.Unmarshal(data, &flashcards); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", filename, err)
+	}
+	return flashcards, nil
+}
+
+// loadFlashcardsFromCSVFile reads flashcards from a file written by exportFlashcardsToCSVFile
+func loadFlashcardsFromCSVFile(filename string) ([]Flashcard, error) {
+	file, err := os.Open(filename) // #nosec G304 -- filename from user CLI arg, expected
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", filename, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// Locate the columns by header so files with and without GUID and Tags columns both load
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	questionCol, hasQuestion := columns["question"]
+	answerCol, hasAnswer := columns["answer"]
+	if !hasQuestion || !hasAnswer {
+		return nil, fmt.Errorf("decoding %s: expected Question and Answer columns in the header", filename)
+	}
+	cell := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var flashcards []Flashcard
+	for _, record := range records[1:] {
+		if questionCol >= len(record) || answerCol >= len(record) {
+			continue
+		}
+		flashcards = append(flashcards, Flashcard{
+			Question: record[questionCol],
+			Answer:   record[answerCol],
+			GUID:     cell(record, "guid"),
+			Tags:     strings.Fields(cell(record, "tags")),
+		})
+	}
+	return flashcards, nil
+}
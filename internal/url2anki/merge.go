@@ -0,0 +1,168 @@
+package url2anki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// mergeConflict records a card that both the user and upstream changed in different ways
+type mergeConflict struct {
+	Key    string     `json:"key"`
+	Base   *Flashcard `json:"base"`
+	Edited *Flashcard `json:"edited"`
+	Fresh  *Flashcard `json:"fresh"`
+}
+
+// mergeResult is the outcome of a three-way merge of flashcard files
+type mergeResult struct {
+	Flashcards []Flashcard
+	Conflicts  []mergeConflict
+	Upstream   int
+	Kept       int
+}
+
+// Merge is the entry point of the merge subcommand, which applies upstream changes to a hand-edited export
+func Merge(cmd *cobra.Command, args []string) error {
+	baseFile, _ := cmd.Flags().GetString("base")
+	editedFile, _ := cmd.Flags().GetString("edited")
+	freshFile, _ := cmd.Flags().GetString("fresh")
+	outputFile, _ := cmd.Flags().GetString("output-file")
+	conflictsFile, _ := cmd.Flags().GetString("conflicts")
+
+	var files [3][]Flashcard
+	for i, filename := range []string{baseFile, editedFile, freshFile} {
+		flashcards, err := loadFlashcardsFromFile(filename)
+		if err != nil {
+			return fmt.Errorf("loading flashcards: %w", err)
+		}
+		files[i] = flashcards
+	}
+
+	result := mergeFlashcards(files[0], files[1], files[2])
+
+	if outputFile == "" {
+		outputFile = editedFile
+	}
+	exporter, err := exporterFor(outputFile, "")
+	if err == nil {
+		err = exporter.Export(result.Flashcards, ExportOptions{DeckName: "Default"}, outputFile)
+	}
+	if err != nil {
+		return fmt.Errorf("exporting merged flashcards: %w", err)
+	}
+	fmt.Printf("Merged flashcards exported to %s: %d upstream changes applied, %d edits kept\n", outputFile, result.Upstream, result.Kept)
+
+	// A clean merge leaves no conflicts file behind, so stale conflicts of an earlier merge are not mistaken for new ones
+	if len(result.Conflicts) == 0 {
+		if err := os.Remove(conflictsFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing old merge conflicts: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(result.Conflicts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("exporting merge conflicts: %w", err)
+	}
+	if err := os.WriteFile(conflictsFile, data, 0600); err != nil { // #nosec G304 G703 -- filename from user CLI arg, expected
+		return fmt.Errorf("exporting merge conflicts: %w", err)
+	}
+	fmt.Printf("%d conflicts kept the edited version and were written to %s for review\n", len(result.Conflicts), conflictsFile)
+	return nil
+}
+
+// mergeFlashcards applies the changes between base and fresh to edited.
+// Cards the user did not touch follow upstream, user edits are kept, and cards both sides changed differently
+// are reported as conflicts while keeping the edited version.
+func mergeFlashcards(base, edited, fresh []Flashcard) mergeResult {
+	var result mergeResult
+
+	// Cards deleted on both sides are simply dropped, so only edited and fresh keys are walked
+	baseCards, _ := indexFlashcards(base)
+	editedCards, editedOrder := indexFlashcards(edited)
+	freshCards, freshOrder := indexFlashcards(fresh)
+
+	// Walk the edited file's order first so the user's arrangement is kept, then append new upstream cards
+	keys := append([]string{}, editedOrder...)
+	for _, key := range freshOrder {
+		if _, ok := editedCards[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		b, inBase := baseCards[key]
+		e, inEdited := editedCards[key]
+		f, inFresh := freshCards[key]
+
+		switch {
+		case sameCard(b, inBase, e, inEdited):
+			// Untouched by the user, so upstream wins, including deletions
+			if inFresh {
+				result.Flashcards = append(result.Flashcards, f)
+			}
+			if !sameCard(b, inBase, f, inFresh) {
+				result.Upstream++
+			}
+		case sameCard(b, inBase, f, inFresh), sameCard(e, inEdited, f, inFresh):
+			// Only the user changed the card, or both made the same change
+			if inEdited {
+				result.Flashcards = append(result.Flashcards, e)
+			}
+			result.Kept++
+		default:
+			result.Conflicts = append(result.Conflicts, mergeConflict{
+				Key:    key,
+				Base:   cardPointer(b, inBase),
+				Edited: cardPointer(e, inEdited),
+				Fresh:  cardPointer(f, inFresh),
+			})
+			if inEdited {
+				result.Flashcards = append(result.Flashcards, e)
+			}
+		}
+	}
+
+	return result
+}
+
+// indexFlashcards maps each card's merge key to the card, returning the keys in file order
+func indexFlashcards(flashcards []Flashcard) (map[string]Flashcard, []string) {
+	index := map[string]Flashcard{}
+	var order []string
+	for _, flashcard := range flashcards {
+		key := mergeKey(flashcard)
+		if _, ok := index[key]; !ok {
+			order = append(order, key)
+		}
+		index[key] = flashcard
+	}
+	return index, order
+}
+
+// mergeKey identifies a card across files by GUID, falling back to its normalized question
+func mergeKey(flashcard Flashcard) string {
+	if flashcard.GUID != "" {
+		return flashcard.GUID
+	}
+	return "question:" + normalizeKey(flashcard.Question)
+}
+
+// sameCard reports whether two possibly-absent cards have the same content
+func sameCard(a Flashcard, aOK bool, b Flashcard, bOK bool) bool {
+	if aOK != bOK {
+		return false
+	}
+	return !aOK || (a.Question == b.Question && a.Answer == b.Answer)
+}
+
+// cardPointer returns a pointer to the card, or nil when it is absent
+func cardPointer(flashcard Flashcard, ok bool) *Flashcard {
+	if !ok {
+		return nil
+	}
+	return &flashcard
+}
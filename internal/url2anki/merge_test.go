@@ -0,0 +1,136 @@
+package url2anki
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestMergeFlashcards tests the mergeFlashcards function
+func TestMergeFlashcards(t *testing.T) {
+	base := []Flashcard{
+		{Question: "Untouched", Answer: "Old", GUID: "g1"},
+		{Question: "Edited", Answer: "Old", GUID: "g2"},
+		{Question: "Both", Answer: "Old", GUID: "g3"},
+		{Question: "Removed upstream", Answer: "Old", GUID: "g4"},
+	}
+	edited := []Flashcard{
+		{Question: "Untouched", Answer: "Old", GUID: "g1"},
+		{Question: "Edited", Answer: "Better wording", GUID: "g2"},
+		{Question: "Both", Answer: "User wording", GUID: "g3"},
+		{Question: "Removed upstream", Answer: "Old", GUID: "g4"},
+		{Question: "Added by user", Answer: "Mine", GUID: "g5"},
+	}
+	fresh := []Flashcard{
+		{Question: "Untouched", Answer: "New upstream", GUID: "g1"},
+		{Question: "Edited", Answer: "Old", GUID: "g2"},
+		{Question: "Both", Answer: "Upstream wording", GUID: "g3"},
+		{Question: "Added upstream", Answer: "Theirs", GUID: "g6"},
+	}
+
+	result := mergeFlashcards(base, edited, fresh)
+
+	expected := []Flashcard{
+		{Question: "Untouched", Answer: "New upstream"},
+		{Question: "Edited", Answer: "Better wording"},
+		{Question: "Both", Answer: "User wording"},
+		{Question: "Added by user", Answer: "Mine"},
+		{Question: "Added upstream", Answer: "Theirs"},
+	}
+	if len(result.Flashcards) != len(expected) {
+		t.Fatalf("Expected %d merged flashcards, got %+v", len(expected), result.Flashcards)
+	}
+	for i, card := range result.Flashcards {
+		if card.Question != expected[i].Question || card.Answer != expected[i].Answer {
+			t.Errorf("Expected flashcard %+v, got %+v", expected[i], card)
+		}
+	}
+
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Key != "g3" {
+		t.Fatalf("Expected a single conflict for g3, got %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Base.Answer != "Old" || conflict.Edited.Answer != "User wording" || conflict.Fresh.Answer != "Upstream wording" {
+		t.Errorf("Expected the conflict to record all three versions, got %+v", conflict)
+	}
+}
+
+// TestLoadFlashcardsFromFile tests that exported JSON and CSV files load back
+func TestLoadFlashcardsFromFile(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1", GUID: "guid1", Tags: []string{"a", "b"}},
+		{Question: "Question 2", Answer: "Answer, with comma", GUID: "guid2"},
+	}
+	dir := t.TempDir()
+
+	exporters := map[string]func([]Flashcard, string) error{
+		"cards.json": exportFlashcardsToJSONFile,
+		"cards.csv":  exportFlashcardsToCSVFile,
+	}
+	for name, export := range exporters {
+		filename := filepath.Join(dir, name)
+		if err := export(flashcards, filename); err != nil {
+			t.Fatalf("Failed to export %s: %v", name, err)
+		}
+
+		loaded, err := loadFlashcardsFromFile(filename)
+		if err != nil {
+			t.Fatalf("loadFlashcardsFromFile(%s) returned an error: %v", name, err)
+		}
+		if len(loaded) != len(flashcards) {
+			t.Fatalf("Expected %d flashcards from %s, got %d", len(flashcards), name, len(loaded))
+		}
+		for i, card := range loaded {
+			if card.Question != flashcards[i].Question || card.Answer != flashcards[i].Answer ||
+				card.GUID != flashcards[i].GUID || len(card.Tags) != len(flashcards[i].Tags) {
+				t.Errorf("Expected flashcard %+v from %s, got %+v", flashcards[i], name, card)
+			}
+		}
+	}
+
+	if _, err := loadFlashcardsFromFile(filepath.Join(dir, "cards.apkg")); err == nil {
+		t.Errorf("Expected an error for an unsupported extension")
+	}
+}
+
+// TestMerge tests that a clean merge removes an old conflicts file and that failures are returned
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	deck := filepath.Join(dir, "deck.json")
+	if err := exportFlashcardsToJSONFile([]Flashcard{{Question: "Pod", Answer: "Unit", GUID: "g1"}}, deck); err != nil {
+		t.Fatalf("Failed to export flashcards: %v", err)
+	}
+	conflicts := filepath.Join(dir, "merge-conflicts.json")
+	if err := os.WriteFile(conflicts, []byte("[]"), 0600); err != nil {
+		t.Fatalf("Failed to write conflicts file: %v", err)
+	}
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("base", deck, "")
+		cmd.Flags().String("edited", deck, "")
+		cmd.Flags().String("fresh", deck, "")
+		cmd.Flags().StringP("output-file", "o", "", "")
+		cmd.Flags().String("conflicts", conflicts, "")
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		return cmd
+	}
+
+	if err := Merge(newCmd(), nil); err != nil {
+		t.Fatalf("Merge returned an error: %v", err)
+	}
+	if _, err := os.Stat(conflicts); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the old conflicts file to be removed, got %v", err)
+	}
+
+	if err := Merge(newCmd("-o", filepath.Join(dir, "deck.unknown")), nil); err == nil {
+		t.Error("Expected an unknown output extension to be refused")
+	}
+	if err := Merge(newCmd("--fresh", filepath.Join(dir, "missing.json")), nil); err == nil {
+		t.Error("Expected a missing input file to be refused")
+	}
+}
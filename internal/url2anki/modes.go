@@ -0,0 +1,153 @@
+package url2anki
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// aliasSeparator joins several <dt> terms that share one definition into a single question
+	aliasSeparator = " / "
+	// definitionSeparator joins several <dd> definitions of one term into a single answer
+	definitionSeparator = "; "
+)
+
+// extractDefinitionLists builds flashcards from every <dl> on the page. Consecutive <dt> terms are
+// aliases sharing the <dd> definitions that follow them, and lists nested inside a definition
+// produce their own flashcards instead of being folded into the outer answer.
+func extractDefinitionLists(doc *goquery.Document, options extractOptions) (extraction, error) {
+	var result extraction
+	questions, answers := 0, 0
+
+	doc.Find("dl").Each(func(_ int, dl *goquery.Selection) {
+		var terms, definitions []*goquery.Selection
+		flush := func() {
+			defer func() { terms, definitions = nil, nil }()
+			if len(terms) == 0 || len(definitions) == 0 {
+				return
+			}
+			// A definition holding nothing but a nested list is a group heading rather than a card
+			answer := joinTexts(definitions, definitionSeparator, excluding("dl", options.Exclude))
+			if answer == "" {
+				return
+			}
+			result.Flashcards = append(result.Flashcards, Flashcard{
+				Question: joinTexts(terms, aliasSeparator, options.Exclude),
+				Answer:   answer,
+			})
+		}
+
+		for _, entry := range definitionListEntries(dl) {
+			switch goquery.NodeName(entry) {
+			case "dt":
+				questions++
+				if len(definitions) > 0 {
+					flush()
+				}
+				terms = append(terms, entry)
+			case "dd":
+				answers++
+				if len(terms) == 0 {
+					result.Skipped = append(result.Skipped, skippedItem{
+						Kind:    "answer",
+						Index:   answers,
+						Path:    domPath(entry.Get(0)),
+						Missing: "question",
+						Snippet: snippet(entry.Text()),
+					})
+					continue
+				}
+				definitions = append(definitions, entry)
+			}
+		}
+		if len(terms) > 0 && len(definitions) == 0 {
+			for i, term := range terms {
+				result.Skipped = append(result.Skipped, skippedItem{
+					Kind:    "question",
+					Index:   questions - len(terms) + i + 1,
+					Path:    domPath(term.Get(0)),
+					Missing: "answer",
+					Snippet: snippet(term.Text()),
+				})
+			}
+		}
+		flush()
+	})
+
+	return result, nil
+}
+
+// definitionListEntries returns the <dt> and <dd> children of dl in document order, looking through
+// the <div> wrappers HTML allows around each term group
+func definitionListEntries(dl *goquery.Selection) []*goquery.Selection {
+	var entries []*goquery.Selection
+	dl.Children().Each(func(_ int, child *goquery.Selection) {
+		switch goquery.NodeName(child) {
+		case "dt", "dd":
+			entries = append(entries, child)
+		case "div":
+			child.ChildrenFiltered("dt, dd").Each(func(_ int, entry *goquery.Selection) {
+				entries = append(entries, entry)
+			})
+		}
+	})
+	return entries
+}
+
+// extractDetails builds a flashcard from every <details> element, using its <summary> as the question
+// and the rest of its content as the answer. Nested <details> produce their own flashcards, and a
+// <details> holding nothing but nested ones is treated as a group heading rather than a card.
+func extractDetails(doc *goquery.Document, options extractOptions) (extraction, error) {
+	var result extraction
+	doc.Find("details").Each(func(i int, details *goquery.Selection) {
+		question := collapseWhitespace(textOf(details.ChildrenFiltered("summary").First(), options.Exclude))
+
+		body := details.Clone()
+		body.ChildrenFiltered("summary").First().Remove()
+		answer := collapseWhitespace(textOf(body, excluding("details", options.Exclude)))
+
+		if answer == "" && details.Find("details").Length() > 0 {
+			return
+		}
+		if missing := missingFields(Flashcard{Question: question, Answer: answer}); missing != "" {
+			result.Skipped = append(result.Skipped, skippedItem{
+				Kind:    "item",
+				Index:   i + 1,
+				Path:    domPath(details.Get(0)),
+				Missing: missing,
+				Snippet: snippet(details.Text()),
+			})
+			return
+		}
+		result.Flashcards = append(result.Flashcards, Flashcard{Question: question, Answer: answer})
+	})
+	return result, nil
+}
+
+// joinTexts cleans and joins the text of each selection, leaving out any descendants matching exclude
+func joinTexts(selections []*goquery.Selection, separator, exclude string) string {
+	texts := make([]string, 0, len(selections))
+	for _, s := range selections {
+		if text := collapseWhitespace(textOf(s, exclude)); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, separator)
+}
+
+// excluding combines selectors into one, skipping empty ones
+func excluding(selectors ...string) string {
+	var nonEmpty []string
+	for _, selector := range selectors {
+		if selector != "" {
+			nonEmpty = append(nonEmpty, selector)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// sanitizeTag turns scraped text into an Anki tag, which cannot contain spaces
+func sanitizeTag(text string) string {
+	return strings.Join(strings.Fields(text), "_")
+}
@@ -0,0 +1,96 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestExtractDefinitionLists tests aliases, multiple definitions, div wrappers and nested lists
+func TestExtractDefinitionLists(t *testing.T) {
+	html := `<html><body>
+		<dl>
+			<dt>Pod</dt><dd>The smallest deployable unit</dd><dd>A group of containers</dd>
+			<dt>Service</dt><dt>svc</dt><dd>An abstract way to expose an app</dd>
+			<div><dt>Workloads</dt><dd>Apps running on Kubernetes
+				<dl><dt>Deployment</dt><dd>Manages ReplicaSets</dd></dl>
+			</dd></div>
+			<dt>Orphan</dt>
+		</dl>
+		<dl><dd>A stray definition</dd></dl>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{Mode: "dl"})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	expected := []Flashcard{
+		{Question: "Pod", Answer: "The smallest deployable unit; A group of containers"},
+		{Question: "Service / svc", Answer: "An abstract way to expose an app"},
+		{Question: "Workloads", Answer: "Apps running on Kubernetes"},
+		{Question: "Deployment", Answer: "Manages ReplicaSets"},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+
+	var skipped []string
+	for _, item := range result.Skipped {
+		skipped = append(skipped, item.Kind+":"+item.Snippet)
+	}
+	if expectedSkipped := []string{"question:Orphan", "answer:A stray definition"}; !reflect.DeepEqual(skipped, expectedSkipped) {
+		t.Errorf("Expected skipped %v, got %v", expectedSkipped, skipped)
+	}
+}
+
+// TestExtractDetails tests that each <details> becomes a card and group-only <details> are passed over
+func TestExtractDetails(t *testing.T) {
+	html := `<html><body>
+		<details><summary>Billing</summary>
+			<details><summary>Can I pay yearly?</summary><p>Yes, with a discount.</p></details>
+			<details><summary>Do you refund?</summary></details>
+		</details>
+		<details><summary>Is there an API?</summary>
+			<p>Yes, a REST API.</p>
+			<p>It is versioned.</p>
+		</details>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{Mode: "details"})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	expected := []Flashcard{
+		{Question: "Can I pay yearly?", Answer: "Yes, with a discount."},
+		{Question: "Is there an API?", Answer: "Yes, a REST API. It is versioned."},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Missing != "answer" {
+		t.Errorf("Expected the unanswered question to be skipped, got %+v", result.Skipped)
+	}
+}
+
+// TestExtractOptionsValidate tests that unknown modes and missing selectors are rejected
+func TestExtractOptionsValidate(t *testing.T) {
+	if err := (extractOptions{Mode: "bogus"}).validate(); err == nil || !strings.Contains(err.Error(), "selectors") {
+		t.Errorf("Expected an unknown mode error listing the modes, got %v", err)
+	}
+	if err := (extractOptions{Mode: selectorsMode, QuestionSelector: "dt"}).validate(); err == nil {
+		t.Error("Expected a missing answer selector to be rejected")
+	}
+	if err := (extractOptions{Mode: "dl"}).validate(); err != nil {
+		t.Errorf("Expected dl mode to need no selectors, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package url2anki
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// pairingEvent is a question or answer element met while walking the document in order
+type pairingEvent struct {
+	Question bool
+	// Index is the 1-based position of the element among the matched questions or answers
+	Index int
+	Node  *html.Node
+	Text  string
+}
+
+// sequenceBreak is a point where the question/answer sequence does not alternate
+type sequenceBreak struct {
+	Description string
+	Path        string
+	Snippet     string
+}
+
+// pairingReport summarises how the question and answer selectors line up in a page
+type pairingReport struct {
+	Questions int
+	Answers   int
+	Breaks    []sequenceBreak
+}
+
+// pairingEvents returns the matched questions and answers in document order
+func pairingEvents(questions, answers []fieldMatch) []pairingEvent {
+	var events []pairingEvent
+	for i, match := range questions {
+		events = append(events, pairingEvent{Question: true, Index: i + 1, Node: match.Node, Text: match.Text})
+	}
+	for i, match := range answers {
+		events = append(events, pairingEvent{Index: i + 1, Node: match.Node, Text: match.Text})
+	}
+	position := documentPositions(events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return position[events[i].Node] < position[events[j].Node]
+	})
+	return events
+}
+
+// documentPositions numbers every node from the root of the events' document in document order
+func documentPositions(events []pairingEvent) map[*html.Node]int {
+	positions := map[*html.Node]int{}
+	if len(events) == 0 {
+		return positions
+	}
+	root := events[0].Node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		positions[n] = len(positions)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return positions
+}
+
+// diagnosePairing counts the questions and answers in doc and reports every place where a question
+// is followed by another question or an answer does not follow a question
+func diagnosePairing(doc *goquery.Document, options extractOptions) (pairingReport, error) {
+	question, answer, _, err := options.fieldSelectors()
+	if err != nil {
+		return pairingReport{}, err
+	}
+	questions, err := question.all(doc.Selection, options.Exclude)
+	if err != nil {
+		return pairingReport{}, err
+	}
+	answers, err := answer.all(doc.Selection, options.Exclude)
+	if err != nil {
+		return pairingReport{}, err
+	}
+	report := pairingReport{Questions: len(questions), Answers: len(answers)}
+
+	events := pairingEvents(questions, answers)
+	for i, event := range events {
+		switch {
+		case event.Question && (i+1 == len(events) || events[i+1].Question):
+			report.Breaks = append(report.Breaks, sequenceBreak{
+				Description: fmt.Sprintf("question %d has no answer", event.Index),
+				Path:        domPath(event.Node),
+				Snippet:     snippet(event.Text),
+			})
+		case !event.Question && (i == 0 || !events[i-1].Question):
+			report.Breaks = append(report.Breaks, sequenceBreak{
+				Description: fmt.Sprintf("answer %d has no question", event.Index),
+				Path:        domPath(event.Node),
+				Snippet:     snippet(event.Text),
+			})
+		}
+	}
+	return report, nil
+}
+
+// print writes the report in a form meant for reading while fixing the selectors
+func (r pairingReport) print(w io.Writer) {
+	fmt.Fprintf(w, "Questions matched: %d\n", r.Questions)
+	fmt.Fprintf(w, "Answers matched:   %d\n", r.Answers)
+	if len(r.Breaks) == 0 {
+		fmt.Fprintln(w, "Questions and answers alternate throughout the page")
+		return
+	}
+	fmt.Fprintf(w, "Sequence breaks:   %d\n", len(r.Breaks))
+	for _, b := range r.Breaks {
+		fmt.Fprintf(w, "  %s\n    at %s: %q\n", b.Description, b.Path, b.Snippet)
+	}
+}
+
+// pairTolerant pairs each question with the next answer after it in document order. Questions followed
+// by another question before any answer and answers without a question are skipped and reported.
+// It also returns the 1-based index of each kept question among all matched questions.
+func pairTolerant(questions, answers []fieldMatch) ([]Flashcard, []int, []skippedItem) {
+	var flashcards []Flashcard
+	var kept []int
+	var skipped []skippedItem
+
+	events := pairingEvents(questions, answers)
+	for i, event := range events {
+		if !event.Question {
+			continue
+		}
+		if i+1 == len(events) || events[i+1].Question {
+			skipped = append(skipped, skippedItem{
+				Kind:    "question",
+				Index:   event.Index,
+				Path:    domPath(event.Node),
+				Missing: "answer",
+				Snippet: snippet(event.Text),
+			})
+			continue
+		}
+		flashcards = append(flashcards, Flashcard{
+			Question: event.Text,
+			Answer:   events[i+1].Text,
+		})
+		kept = append(kept, event.Index)
+	}
+	for i, event := range events {
+		if !event.Question && (i == 0 || !events[i-1].Question) {
+			skipped = append(skipped, skippedItem{
+				Kind:    "answer",
+				Index:   event.Index,
+				Path:    domPath(event.Node),
+				Missing: "question",
+				Snippet: snippet(event.Text),
+			})
+		}
+	}
+	return flashcards, kept, skipped
+}
+
+// domPath describes where n sits in the document as a CSS-like path (EX: body > dl:nth-of-type(2) > dt:nth-of-type(4))
+func domPath(n *html.Node) string {
+	if n != nil && n.Type == html.TextNode {
+		n = n.Parent
+	}
+	var parts []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if n.Data == "html" {
+			break
+		}
+		part := n.Data
+		if id := attr(n, "id"); id != "" {
+			parts = append([]string{part + "#" + id}, parts...)
+			break
+		}
+		if position, count := typePosition(n); count > 1 {
+			part += fmt.Sprintf(":nth-of-type(%d)", position)
+		}
+		parts = append([]string{part}, parts...)
+	}
+	return strings.Join(parts, " > ")
+}
+
+// typePosition returns n's 1-based position among its siblings with the same tag, and how many there are
+func typePosition(n *html.Node) (int, int) {
+	if n.Parent == nil {
+		return 1, 1
+	}
+	position, count := 0, 0
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == n.Data {
+			count++
+			if c == n {
+				position = count
+			}
+		}
+	}
+	return position, count
+}
+
+// attr returns the value of n's attribute called name, or "" when it has none
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
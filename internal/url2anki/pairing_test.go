@@ -0,0 +1,75 @@
+package url2anki
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// brokenGlossary has a term without a definition and a definition without a term
+const brokenGlossary = `<html><body><dl id="glossary">
+	<dt>Pod</dt><dd>The smallest deployable unit</dd>
+	<dt>Node</dt>
+	<dt>Service</dt><dd>An abstract way to expose an app</dd>
+	<dd>A stray definition</dd>
+	<dt>Volume</dt><dd>A directory</dd>
+</dl></body></html>`
+
+// TestDiagnosePairing tests that diagnosePairing counts both sides and locates every break
+func TestDiagnosePairing(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(brokenGlossary))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	report, err := diagnosePairing(doc, extractOptions{QuestionSelector: "dt", AnswerSelector: "dd"})
+	if err != nil {
+		t.Fatalf("diagnosePairing returned an error: %v", err)
+	}
+	if report.Questions != 4 || report.Answers != 4 {
+		t.Errorf("Expected 4 questions and 4 answers, got %d and %d", report.Questions, report.Answers)
+	}
+	expected := []sequenceBreak{
+		{Description: "question 2 has no answer", Path: "dl#glossary > dt:nth-of-type(2)", Snippet: "Node"},
+		{Description: "answer 3 has no question", Path: "dl#glossary > dd:nth-of-type(3)", Snippet: "A stray definition"},
+	}
+	if !reflect.DeepEqual(report.Breaks, expected) {
+		t.Errorf("Expected breaks %+v, got %+v", expected, report.Breaks)
+	}
+
+	var out bytes.Buffer
+	report.print(&out)
+	if !strings.Contains(out.String(), "Sequence breaks:   2") {
+		t.Errorf("Expected the report to count the breaks, got %s", out.String())
+	}
+}
+
+// TestExtractTolerant tests that tolerant pairing keeps the aligned cards and skips the rest
+func TestExtractTolerant(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(brokenGlossary))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	if _, err := extract(doc, extractOptions{QuestionSelector: "dt", AnswerSelector: "dd", GUIDSelector: "dt"}); err != nil {
+		t.Fatalf("Expected equal counts to pass strict pairing, got %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{QuestionSelector: "dt", AnswerSelector: "dd", GUIDSelector: "dt", Tolerant: true})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	var questions []string
+	for _, flashcard := range result.Flashcards {
+		questions = append(questions, flashcard.Question)
+	}
+	if expected := []string{"Pod", "Service", "Volume"}; !reflect.DeepEqual(questions, expected) || !reflect.DeepEqual(result.Keys, expected) {
+		t.Errorf("Expected questions and keys %v, got %v and %v", expected, questions, result.Keys)
+	}
+	if len(result.Skipped) != 2 || result.Skipped[0].Kind != "question" || result.Skipped[1].Kind != "answer" {
+		t.Errorf("Expected a skipped question and a skipped answer, got %+v", result.Skipped)
+	}
+}
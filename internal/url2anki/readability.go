@@ -0,0 +1,166 @@
+package url2anki
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	// boilerplateSelector matches elements that are page chrome whatever their content
+	boilerplateSelector = "script, style, noscript, template, iframe, nav, header, footer, aside, form, " +
+		"[role=navigation], [role=banner], [role=contentinfo], [role=complementary], [role=dialog]"
+	// mainSelector matches elements a page marks as its main content
+	mainSelector = "main, [role=main]"
+	// contentSelector matches the elements whose text is scored to find the main content
+	contentSelector = "p, pre, td, li, dd, dt, blockquote"
+	// minScoredText is the shortest text worth scoring, which keeps menus and captions out
+	minScoredText = 25
+	// containerSelector matches the elements whose class or id can mark them as boilerplate; headings,
+	// list items and inline elements with such hints (EX: h2.section-header, span.token.comment) are content
+	containerSelector = "div, section, aside, ul, form"
+)
+
+var (
+	// unlikelyContent matches the class and id of banners, menus, comment threads and the like
+	unlikelyContent = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|consent|disqus|extra|foot|gdpr|header|menu|modal|nav|pager|popup|promo|related|remark|rss|share|shoutbox|sidebar|skip|social|sponsor|subscribe|tweet|widget`)
+	// likelyContent matches the class and id of article bodies, overriding unlikelyContent
+	likelyContent = regexp.MustCompile(`(?i)article|body|column|content|entry|glossary|main|post|story|text`)
+)
+
+// isolateMainContent returns a copy of doc holding only its main article content, dropping navigation,
+// footers, cookie banners and other boilerplate so broad selectors only match the page's real content.
+// Pages marking a single <main> element keep it; otherwise containers are scored the way readability
+// tools do, by the amount of prose they hold less the share of it that is link text. Containers hinted
+// as boilerplate by their class or id are only left out of the scoring and of pages without a clear
+// candidate, so nothing inside the chosen content is removed.
+func isolateMainContent(doc *goquery.Document) *goquery.Document {
+	clean := goquery.NewDocumentFromNode(doc.Selection.Clone().Get(0))
+	// An article's own <header> holds its title rather than site chrome
+	clean.Find(boilerplateSelector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return goquery.NodeName(s) != "header" || s.Closest("article, main").Length() == 0
+	}).Remove()
+
+	if main := clean.Find(mainSelector); main.Length() == 1 {
+		return documentOf(clean, main)
+	}
+	if best := bestContentCandidate(clean); best != nil {
+		return documentOf(clean, best)
+	}
+
+	// Without a candidate, drop the hinted containers unless that loses most of the page's text, the way
+	// readability tools retry without their heuristics
+	pruned := goquery.NewDocumentFromNode(clean.Selection.Clone().Get(0))
+	pruned.Find(containerSelector).FilterFunction(isUnlikelyContainer).Remove()
+	if textLength(pruned.Selection)*2 < textLength(clean.Selection) {
+		return clean
+	}
+	return pruned
+}
+
+// isUnlikelyContainer reports whether s is a container whose class or id marks it as boilerplate
+func isUnlikelyContainer(_ int, s *goquery.Selection) bool {
+	if !s.Is(containerSelector) {
+		return false
+	}
+	hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+	return unlikelyContent.MatchString(hint) && !likelyContent.MatchString(hint)
+}
+
+// textLength returns the length of s's text with its whitespace collapsed
+func textLength(s *goquery.Selection) int {
+	return len(collapseWhitespace(s.Text()))
+}
+
+// bestContentCandidate scores the parents and grandparents of every paragraph-like element outside hinted
+// boilerplate containers and returns the highest scoring one, or nil when the page has no prose at all
+func bestContentCandidate(doc *goquery.Document) *goquery.Selection {
+	scores := map[*html.Node]float64{}
+	// order keeps ties resolved in document order
+	var order []*html.Node
+	score := func(n *html.Node, points float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialContentScore(goquery.NewDocumentFromNode(n).Selection)
+			order = append(order, n)
+		}
+		scores[n] += points
+	}
+
+	doc.Find(contentSelector).Each(func(_ int, s *goquery.Selection) {
+		text := collapseWhitespace(s.Text())
+		if len(text) < minScoredText || s.ParentsFiltered(containerSelector).FilterFunction(isUnlikelyContainer).Length() > 0 {
+			return
+		}
+		points := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		if parent := s.Get(0).Parent; parent != nil {
+			score(parent, points)
+			score(parent.Parent, points/2)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		points := scores[n] * (1 - linkDensity(goquery.NewDocumentFromNode(n).Selection))
+		if points > bestScore {
+			best, bestScore = n, points
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return doc.FindNodes(best)
+}
+
+// initialContentScore favours elements that usually wrap articles and penalises hinted boilerplate
+func initialContentScore(s *goquery.Selection) float64 {
+	score := 0.0
+	switch goquery.NodeName(s) {
+	case "article", "section", "div":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "ol", "ul", "dl", "dd", "dt", "li":
+		score -= 3
+	}
+	hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+	if likelyContent.MatchString(hint) {
+		score += 25
+	}
+	if unlikelyContent.MatchString(hint) {
+		score -= 25
+	}
+	return score
+}
+
+// linkDensity returns the share of s's text that sits inside links
+func linkDensity(s *goquery.Selection) float64 {
+	total := textLength(s)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += textLength(a)
+	})
+	return float64(links) / float64(total)
+}
+
+// documentOf returns a new document holding doc's <head> and the content selection as its <body>
+func documentOf(doc *goquery.Document, content *goquery.Selection) *goquery.Document {
+	head, _ := goquery.OuterHtml(doc.Find("head").First())
+	body, err := goquery.OuterHtml(content.First())
+	if err != nil {
+		return doc
+	}
+	isolated, err := goquery.NewDocumentFromReader(strings.NewReader("<html>" + head + "<body>" + body + "</body></html>"))
+	if err != nil {
+		return doc
+	}
+	return isolated
+}
@@ -0,0 +1,92 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestIsolateMainContent tests that boilerplate around the article is dropped for every mode
+func TestIsolateMainContent(t *testing.T) {
+	html := `<html><head><title>Glossary</title></head><body>
+		<nav><ul><li>Home page of the documentation site</li><li>Another menu entry for navigation</li></ul></nav>
+		<div class="cookie-banner"><p>We use cookies to improve your experience, please accept them.</p></div>
+		<div class="sidebar"><ul><li><a href="/a">A related page with a long link title here</a></li></ul></div>
+		<div class="wrapper"><div class="article-body">
+			<p>A Pod is the smallest deployable unit, grouping one or more containers.</p>
+			<p>A Node is a worker machine, virtual or physical, that runs Pods.</p>
+		</div></div>
+		<footer><p>Copyright the authors, all rights reserved, licensed under CC BY 4.0.</p></footer>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	isolated := isolateMainContent(doc)
+	var paragraphs []string
+	isolated.Find("p, li").Each(func(_ int, s *goquery.Selection) {
+		paragraphs = append(paragraphs, strings.Fields(s.Text())[1])
+	})
+	if expected := []string{"Pod", "Node"}; !reflect.DeepEqual(paragraphs, expected) {
+		t.Errorf("Expected only the article paragraphs, got %v", paragraphs)
+	}
+	if title := isolated.Find("title").Text(); title != "Glossary" {
+		t.Errorf("Expected the page title to be kept, got %q", title)
+	}
+	if doc.Find("nav").Length() != 1 {
+		t.Error("Expected the original document to be left untouched")
+	}
+
+	// Pages marking their main element keep exactly that element
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<body><div class="menu"><p>Menu</p></div><main><h2>Pod</h2><p>Unit</p></main></body>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	result, err := extract(doc, extractOptions{Mode: "sections", MainContent: true})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	if expected := []Flashcard{{Question: "Pod", Answer: "Unit"}}; !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+}
+
+// TestIsolateMainContentKeepsHintedContent tests that headings, terms and code whose class merely looks
+// like boilerplate survive inside the article, while hinted containers outside it are still dropped
+func TestIsolateMainContentKeepsHintedContent(t *testing.T) {
+	html := `<body>
+		<div class="comments"><p>A comment about the article that is long enough to be scored.</p></div>
+		<div class="article-body">
+			<h2 class="section-header">Pods</h2>
+			<p>A Pod is the smallest deployable unit, grouping one or more containers.</p>
+			<dl><dt class="menu-item">Node</dt><dd>A worker machine, virtual or physical, that runs Pods.</dd></dl>
+			<pre><span class="token comment"># list the pods</span> kubectl get pods</pre>
+		</div>
+	</body>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	isolated := isolateMainContent(doc)
+	for _, selector := range []string{"h2.section-header", "dt.menu-item", "pre span.comment"} {
+		if isolated.Find(selector).Length() != 1 {
+			t.Errorf("Expected %s to be kept", selector)
+		}
+	}
+	if isolated.Find(".comments").Length() != 0 {
+		t.Error("Expected the comments container to be dropped")
+	}
+
+	// Without any prose to score, a page whose content sits in a hinted container is kept whole
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<body><div class="extra"><h2>Pod</h2><h2>Node</h2></div></body>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	if headings := isolateMainContent(doc).Find("h2").Length(); headings != 2 {
+		t.Errorf("Expected the unpruned page to be kept, got %d headings", headings)
+	}
+}
@@ -0,0 +1,154 @@
+package url2anki
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	// defaultHeadingSelector picks the headings turned into questions in sections mode
+	defaultHeadingSelector = "h2,h3"
+	// tagHierarchySeparator nests one Anki tag under another
+	tagHierarchySeparator = "::"
+)
+
+var (
+	// skippedElements hold no readable page content
+	skippedElements = map[string]bool{"script": true, "style": true, "noscript": true, "template": true}
+	// inlineElements run on with the text around them, while every other element breaks words apart
+	inlineElements = map[string]bool{
+		"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true, "data": true,
+		"dfn": true, "em": true, "i": true, "kbd": true, "mark": true, "q": true, "s": true, "samp": true,
+		"small": true, "span": true, "strong": true, "sub": true, "sup": true, "time": true, "u": true, "var": true,
+	}
+)
+
+// sectionBlock is a heading or a run of text met while walking the page in document order
+type sectionBlock struct {
+	Heading *html.Node
+	// Level is the heading's level from 1 to 6, or 0 for text
+	Level int
+	Text  string
+}
+
+// extractSections builds a flashcard from every heading matched by the heading selector, answering it with
+// the text up to the next heading of the same or a higher level. The chosen headings above a card become a
+// hierarchical tag (EX: Workloads::Pods) so the deck keeps the page's structure.
+func extractSections(doc *goquery.Document, options extractOptions) (extraction, error) {
+	var result extraction
+	headingSelector := options.HeadingSelector
+	if headingSelector == "" {
+		headingSelector = defaultHeadingSelector
+	}
+
+	chosen := map[*html.Node]bool{}
+	doc.Find(headingSelector).Each(func(_ int, s *goquery.Selection) {
+		if headingLevel(s.Get(0)) > 0 {
+			chosen[s.Get(0)] = true
+		}
+	})
+
+	blocks := sectionBlocks(doc, options.Exclude)
+	// parents holds the chosen headings enclosing the current position, outermost first
+	var parents []sectionBlock
+	index := 0
+	for i, block := range blocks {
+		if block.Heading == nil {
+			continue
+		}
+		for len(parents) > 0 && parents[len(parents)-1].Level >= block.Level {
+			parents = parents[:len(parents)-1]
+		}
+		if !chosen[block.Heading] {
+			continue
+		}
+		index++
+
+		var answer strings.Builder
+		for _, next := range blocks[i+1:] {
+			if next.Heading != nil && next.Level <= block.Level {
+				break
+			}
+			if next.Heading != nil {
+				// Headings are not walked into, so they are spaced here like any other block
+				answer.WriteString(" " + next.Text + " ")
+			} else {
+				answer.WriteString(next.Text)
+			}
+		}
+		flashcard := Flashcard{
+			Question: block.Text,
+			Answer:   collapseWhitespace(answer.String()),
+		}
+
+		if missing := missingFields(flashcard); missing != "" {
+			result.Skipped = append(result.Skipped, skippedItem{
+				Kind:    "section",
+				Index:   index,
+				Path:    domPath(block.Heading),
+				Missing: missing,
+				Snippet: snippet(block.Text),
+			})
+		} else {
+			if len(parents) > 0 {
+				tags := make([]string, len(parents))
+				for j, parent := range parents {
+					tags[j] = sanitizeTag(parent.Text)
+				}
+				flashcard.Tags = []string{strings.Join(tags, tagHierarchySeparator)}
+			}
+			result.Flashcards = append(result.Flashcards, flashcard)
+		}
+		parents = append(parents, block)
+	}
+	return result, nil
+}
+
+// sectionBlocks flattens the page into headings and the raw text nodes between them, in document order,
+// leaving out elements matching exclude. A space follows every block element so its words stay apart
+// from the next one's, while inline markup (EX: the <em>smallest</em>.) adds no space.
+func sectionBlocks(doc *goquery.Document, exclude string) []sectionBlock {
+	excluded := map[*html.Node]bool{}
+	if exclude != "" {
+		doc.Find(exclude).Each(func(_ int, s *goquery.Selection) { excluded[s.Get(0)] = true })
+	}
+
+	var blocks []sectionBlock
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			blocks = append(blocks, sectionBlock{Text: n.Data})
+			return
+		case n.Type == html.ElementNode && (skippedElements[n.Data] || excluded[n]):
+			return
+		case n.Type == html.ElementNode && headingLevel(n) > 0:
+			blocks = append(blocks, sectionBlock{
+				Heading: n,
+				Level:   headingLevel(n),
+				Text:    collapseWhitespace(textOf(goquery.NewDocumentFromNode(n).Selection, exclude)),
+			})
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && !inlineElements[n.Data] {
+			blocks = append(blocks, sectionBlock{Text: " "})
+		}
+	}
+	for _, root := range doc.Nodes {
+		walk(root)
+	}
+	return blocks
+}
+
+// headingLevel returns the level of an <h1> to <h6> element, or 0 for any other node
+func headingLevel(n *html.Node) int {
+	if n.Type != html.ElementNode || len(n.Data) != 2 || n.Data[0] != 'h' || n.Data[1] < '1' || n.Data[1] > '6' {
+		return 0
+	}
+	return int(n.Data[1] - '0')
+}
@@ -0,0 +1,56 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestExtractSections tests that headings answer with the content below them and tag their parents
+func TestExtractSections(t *testing.T) {
+	html := `<html><body>
+		<h1>Kubernetes</h1>
+		<h2>Workloads</h2>
+		<p>Apps running on a cluster.</p>
+		<section><h3>Pods</h3><p>The smallest</p><p>deployable unit.</p><script>track()</script></section>
+		<h4>Pod lifecycle</h4><p>Pending, Running, Succeeded.</p>
+		<h3>Empty</h3>
+		<h2>Storage Basics</h2>
+		<h3>Volumes</h3><p>Directories <em>mounted</em> into <b>contain</b>ers, the <em>smallest</em>.</p>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{Mode: "sections"})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "Workloads", Answer: "Apps running on a cluster. Pods The smallest deployable unit. Pod lifecycle Pending, Running, Succeeded. Empty"},
+		{Question: "Pods", Answer: "The smallest deployable unit. Pod lifecycle Pending, Running, Succeeded.", Tags: []string{"Workloads"}},
+		{Question: "Storage Basics", Answer: "Volumes Directories mounted into containers, the smallest."},
+		{Question: "Volumes", Answer: "Directories mounted into containers, the smallest.", Tags: []string{"Storage_Basics"}},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Snippet != "Empty" {
+		t.Errorf("Expected the empty section to be skipped, got %+v", result.Skipped)
+	}
+
+	// Deeper headings nest their tags under every chosen parent
+	result, err = extract(doc, extractOptions{Mode: "sections", HeadingSelector: "h2,h3,h4"})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	for _, flashcard := range result.Flashcards {
+		if flashcard.Question == "Pod lifecycle" && !reflect.DeepEqual(flashcard.Tags, []string{"Workloads::Pods"}) {
+			t.Errorf("Expected the Workloads::Pods tag, got %v", flashcard.Tags)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package url2anki
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+const (
+	// selectorPartSeparator splits a field selector into parts whose values are concatenated
+	selectorPartSeparator = "&&"
+	// defaultFieldSeparator joins the values of a field selector's parts
+	defaultFieldSeparator = " "
+	// xpathPrefix marks a selector as an XPath expression rather than CSS
+	xpathPrefix = "xpath:"
+)
+
+var (
+	// attributeSuffix matches the @attr suffix that reads an attribute instead of the element's text
+	attributeSuffix = regexp.MustCompile(`^(.*?)@([A-Za-z_][-\w:.]*)$`)
+	// xpathAttributeStep matches an XPath expression ending in an attribute step (EX: //abbr/@title)
+	xpathAttributeStep = regexp.MustCompile(`^(.*)/@([A-Za-z_][-\w:.]*)$`)
+)
+
+// selectorPart is one CSS selector or XPath expression of a field selector, optionally reading an
+// attribute (EX: abbr@title, xpath://abbr/@title)
+type selectorPart struct {
+	Selector string
+	Attr     string
+	// XPath is the compiled expression when the part was written with the xpath: prefix
+	XPath *xpath.Expr
+}
+
+// fieldSelector picks a flashcard field out of the page. It is written as one or more parts separated by
+// "&&", each a CSS selector with an optional @attr suffix or an XPath expression prefixed with xpath:
+// (EX: "@data-term && xpath:following-sibling::dd[1]" with --item-selector dt); the values of the parts are
+// joined with the separator. A part of just @attr reads the item container itself.
+type fieldSelector struct {
+	Parts     []selectorPart
+	Separator string
+}
+
+// fieldMatch is one value of a field selector matched across the whole page
+type fieldMatch struct {
+	// Node is the element matched by the first part, which places the value in the document
+	Node *html.Node
+	Text string
+}
+
+// parseFieldSelector parses the field selector syntax, joining part values with separator
+func parseFieldSelector(s, separator string) (fieldSelector, error) {
+	if separator == "" {
+		separator = defaultFieldSeparator
+	}
+	field := fieldSelector{Separator: separator}
+	if strings.TrimSpace(s) == "" {
+		return field, nil
+	}
+	for _, raw := range strings.Split(s, selectorPartSeparator) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			return fieldSelector{}, fmt.Errorf("selector %q has an empty part", s)
+		}
+		part, err := parseSelectorPart(raw)
+		if err != nil {
+			return fieldSelector{}, err
+		}
+		field.Parts = append(field.Parts, part)
+	}
+	return field, nil
+}
+
+// parseSelectorPart parses a single CSS selector or xpath:-prefixed expression with its attribute
+func parseSelectorPart(s string) (selectorPart, error) {
+	if expr, ok := strings.CutPrefix(s, xpathPrefix); ok {
+		part := selectorPart{Selector: strings.TrimSpace(expr)}
+		// Read attributes off their element so every match stays placed in the document
+		if m := xpathAttributeStep.FindStringSubmatch(part.Selector); m != nil && m[1] != "" && !strings.HasSuffix(m[1], "/") {
+			part = selectorPart{Selector: m[1], Attr: m[2]}
+		}
+		if selectsAttributes(part.Selector) {
+			return selectorPart{}, fmt.Errorf("XPath %q selects attributes without their element, end it with an element step and /@attr instead (EX: //abbr/@title)", expr)
+		}
+		compiled, err := xpath.Compile(part.Selector)
+		if err != nil {
+			return selectorPart{}, fmt.Errorf("invalid XPath %q: %w", part.Selector, err)
+		}
+		// Functions like string() and count() return a value rather than elements, which would match nothing
+		if _, ok := compiled.Evaluate(htmlquery.CreateXPathNavigator(&html.Node{Type: html.DocumentNode})).(*xpath.NodeIterator); !ok {
+			return selectorPart{}, fmt.Errorf("XPath %q returns a value rather than elements, select the elements whose text is wanted instead", expr)
+		}
+		part.XPath = compiled
+		return part, nil
+	}
+
+	if m := attributeSuffix.FindStringSubmatch(s); m != nil {
+		return selectorPart{Selector: strings.TrimSpace(m[1]), Attr: m[2]}, nil
+	}
+	return selectorPart{Selector: s}, nil
+}
+
+// selectsAttributes reports whether an XPath expression steps onto an attribute axis outside its predicates
+// and string literals (EX: //@title, @href, //a/attribute::href)
+func selectsAttributes(expr string) bool {
+	depth := 0
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[' || r == '(':
+			depth++
+		case r == ']' || r == ')':
+			depth--
+		case depth == 0 && (r == '@' || strings.HasPrefix(expr[i:], "attribute::")):
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether no selector was given
+func (f fieldSelector) empty() bool {
+	return len(f.Parts) == 0
+}
+
+// find returns the elements the part matches inside root, or root itself for a bare @attr part.
+// XPath expressions are evaluated with each node of root as the context node, so relative paths
+// (EX: .//h3) stay inside an item while axes like ancestor:: can still reach the rest of the page.
+func (p selectorPart) find(root *goquery.Selection) *goquery.Selection {
+	if p.Selector == "" {
+		return root
+	}
+	if p.XPath == nil {
+		return root.Find(p.Selector)
+	}
+	var nodes []*html.Node
+	for _, n := range root.Nodes {
+		matches := p.XPath.Select(xpathNavigatorAt(n))
+		for matches.MoveNext() {
+			nodes = append(nodes, matches.Current().(*htmlquery.NodeNavigator).Current())
+		}
+	}
+	// Start from an empty selection of its own, since appending to root.Slice(0, 0) would overwrite root
+	return new(goquery.Selection).AddNodes(nodes...)
+}
+
+// xpathNavigatorAt returns an XPath navigator over n's whole document, positioned on n
+func xpathNavigatorAt(n *html.Node) *htmlquery.NodeNavigator {
+	var path []*html.Node
+	top := n
+	for ; top.Parent != nil; top = top.Parent {
+		path = append([]*html.Node{top}, path...)
+	}
+
+	nav := htmlquery.CreateXPathNavigator(top)
+	for _, step := range path {
+		nav.MoveToChild()
+		for nav.Current() != step && nav.MoveToNext() {
+		}
+	}
+	return nav
+}
+
+// value returns the part's attribute of s, or the text of s without the descendants matching exclude
+func (p selectorPart) value(s *goquery.Selection, exclude string) string {
+	if p.Attr != "" {
+		return s.AttrOr(p.Attr, "")
+	}
+	return textOf(s, exclude)
+}
+
+// join cleans the part values with clean and joins the non-empty ones with the separator
+func (f fieldSelector) join(values []string, clean func(string) string) string {
+	var kept []string
+	for _, value := range values {
+		if value = clean(value); value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return strings.Join(kept, f.Separator)
+}
+
+// all matches the field across root, pairing the nth match of every part into the nth value
+func (f fieldSelector) all(root *goquery.Selection, exclude string) ([]fieldMatch, error) {
+	var matches []fieldMatch
+	var parts []*goquery.Selection
+	for i, part := range f.Parts {
+		found := part.find(root)
+		if i > 0 && found.Length() != parts[0].Length() {
+			return nil, fmt.Errorf("the parts of selector %q match %d and %d elements", f.String(), parts[0].Length(), found.Length())
+		}
+		parts = append(parts, found)
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	for i := 0; i < parts[0].Length(); i++ {
+		values := make([]string, len(parts))
+		for j, part := range f.Parts {
+			values[j] = part.value(parts[j].Eq(i), exclude)
+		}
+		matches = append(matches, fieldMatch{Node: parts[0].Get(i), Text: f.join(values, cleanText)})
+	}
+	return matches, nil
+}
+
+// within evaluates the field inside a single container. With first set each part reads only its
+// first match; otherwise the text of all its matches is joined with spaces. Whitespace is collapsed, so
+// text spread over several lines or elements keeps its word breaks.
+func (f fieldSelector) within(root *goquery.Selection, exclude string, first bool) string {
+	values := make([]string, len(f.Parts))
+	for i, part := range f.Parts {
+		found := part.find(root)
+		if first || part.Attr != "" {
+			found = found.First()
+		}
+		texts := make([]string, 0, found.Length())
+		found.Each(func(_ int, s *goquery.Selection) {
+			texts = append(texts, part.value(s, exclude))
+		})
+		values[i] = strings.Join(texts, " ")
+	}
+	return f.join(values, collapseWhitespace)
+}
+
+// String returns the field selector in the syntax it was parsed from
+func (f fieldSelector) String() string {
+	parts := make([]string, len(f.Parts))
+	for i, part := range f.Parts {
+		parts[i] = part.String()
+	}
+	return strings.Join(parts, " "+selectorPartSeparator+" ")
+}
+
+// String returns the part in the syntax it was parsed from
+func (p selectorPart) String() string {
+	switch {
+	case p.XPath != nil && p.Attr != "":
+		return xpathPrefix + p.Selector + "/@" + p.Attr
+	case p.XPath != nil:
+		return xpathPrefix + p.Selector
+	case p.Attr != "":
+		return p.Selector + "@" + p.Attr
+	}
+	return p.Selector
+}
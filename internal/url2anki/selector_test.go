@@ -0,0 +1,160 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestParseFieldSelector tests the @attr suffix and && concatenation syntax
+func TestParseFieldSelector(t *testing.T) {
+	field, err := parseFieldSelector("li@data-term && a[href^='#']@title && span.alias", " / ")
+	if err != nil {
+		t.Fatalf("parseFieldSelector returned an error: %v", err)
+	}
+	expected := []selectorPart{
+		{Selector: "li", Attr: "data-term"},
+		{Selector: "a[href^='#']", Attr: "title"},
+		{Selector: "span.alias"},
+	}
+	if !reflect.DeepEqual(field.Parts, expected) || field.Separator != " / " {
+		t.Errorf("Expected parts %+v, got %+v", expected, field)
+	}
+
+	if _, err := parseFieldSelector("dt && ", ""); err == nil {
+		t.Error("Expected an empty part to be rejected")
+	}
+}
+
+// TestExtractAttributes tests reading questions from attributes and joining several parts into one answer
+func TestExtractAttributes(t *testing.T) {
+	html := `<html><body><ul>
+		<li data-term="Pod"><abbr title="Smallest deployable unit">SDU</abbr><img alt="Pod diagram"></li>
+		<li data-term="Node"><abbr title="Worker machine">WM</abbr><img alt="Node diagram"></li>
+	</ul></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "Pod", Answer: "Smallest deployable unit - Pod diagram"},
+		{Question: "Node", Answer: "Worker machine - Node diagram"},
+	}
+
+	// Page-wide pairing, with the nth match of every part joined
+	result, err := extract(doc, extractOptions{QuestionSelector: "li@data-term", AnswerSelector: "abbr@title && img@alt", FieldSeparator: " - "})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+
+	// Container-scoped pairing, where a bare @attr reads the container
+	result, err = extract(doc, extractOptions{ItemSelector: "li", QuestionSelector: "@data-term", AnswerSelector: "abbr@title && img@alt", FieldSeparator: " - "})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+}
+
+// TestExtractXPath tests XPath selectors for axes CSS cannot express, page-wide and inside items
+func TestExtractXPath(t *testing.T) {
+	html := `<html><body>
+		<h2>Pod</h2><p>The smallest deployable unit.</p><p>More detail.</p>
+		<h2>Node</h2><p>A worker machine.</p>
+		<table>
+			<thead><tr><th>Flag</th><th>Type</th><th>Description</th></tr></thead>
+			<tbody>
+				<tr><td><abbr title="--output-file">-o</abbr></td><td>string</td><td>The file to export to</td></tr>
+				<tr><td><abbr title="--preview">-p</abbr></td><td>bool</td><td>Preview the cards</td></tr>
+			</tbody>
+		</table>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	// The paragraph following each heading
+	result, err := extract(doc, extractOptions{QuestionSelector: "xpath://h2", AnswerSelector: "xpath://h2/following-sibling::p[1]"})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	expected := []Flashcard{
+		{Question: "Pod", Answer: "The smallest deployable unit."},
+		{Question: "Node", Answer: "A worker machine."},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+
+	// The cell under the Description header, inside XPath item containers, with an XPath attribute step
+	result, err = extract(doc, extractOptions{
+		ItemSelector:     "xpath://tbody/tr",
+		QuestionSelector: "xpath:.//abbr/@title",
+		AnswerSelector:   "xpath:td[count(../../../thead/tr/th[.='Description']/preceding-sibling::th)+1]",
+	})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	expected = []Flashcard{
+		{Question: "--output-file", Answer: "The file to export to"},
+		{Question: "--preview", Answer: "Preview the cards"},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+
+	// A bare @attr part joined with a relative XPath part, inside <dt> item containers
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<dl>
+		<dt data-term="Pod" title="Workloads">P</dt><dd>The smallest deployable unit.</dd>
+		<dt data-term="Node" title="Cluster">N</dt><dd>A worker machine.</dd>
+	</dl>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	result, err = extract(doc, extractOptions{
+		ItemSelector:     "dt",
+		QuestionSelector: "@title",
+		AnswerSelector:   "@data-term && xpath:following-sibling::dd[1]",
+		FieldSeparator:   ": ",
+	})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+	expected = []Flashcard{
+		{Question: "Workloads", Answer: "Pod: The smallest deployable unit."},
+		{Question: "Cluster", Answer: "Node: A worker machine."},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+
+	if err := (extractOptions{Mode: selectorsMode, QuestionSelector: "xpath://h2[", AnswerSelector: "p"}).validate(); err == nil {
+		t.Error("Expected an invalid XPath to be rejected")
+	}
+	for selector, message := range map[string]string{
+		"xpath:string(//h2)":                 "returns a value",
+		"xpath:count(//p)":                   "returns a value",
+		"xpath:normalize-space(//h2)":        "returns a value",
+		"xpath://@title":                     "selects attributes",
+		"xpath:@title":                       "selects attributes",
+		"xpath://abbr/attribute::title":      "selects attributes",
+		"xpath://abbr[@title]/@title":        "",
+		"xpath://p[contains(., '@home')]":    "",
+		"xpath://h2/following-sibling::p[1]": "",
+	} {
+		_, err := parseSelectorPart(selector)
+		if message == "" && err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", selector, err)
+		} else if message != "" && (err == nil || !strings.Contains(err.Error(), message)) {
+			t.Errorf("Expected %s to be rejected as it %s, got %v", selector, message, err)
+		}
+	}
+}
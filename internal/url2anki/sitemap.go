@@ -0,0 +1,268 @@
+package url2anki
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxSitemapDepth bounds how deeply sitemap indexes may nest, guarding against indexes that list themselves
+const maxSitemapDepth = 5
+
+// lastModDateLayout is the date-only form of <lastmod>, which says on which day but not when a page changed
+const lastModDateLayout = "2006-01-02"
+
+// lastModLayouts are the W3C datetime forms a sitemap's <lastmod> may take
+var lastModLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00", lastModDateLayout}
+
+// sitemapEntry is a page listed in a sitemap, with the time it last changed when the sitemap says
+type sitemapEntry struct {
+	URL     string
+	LastMod time.Time
+	// DateOnly is set when the lastmod gave a date without a time, read as midnight UTC
+	DateOnly bool
+}
+
+// sitemapDocument is either a <urlset> listing pages or a <sitemapindex> listing further sitemaps
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLocation `xml:"url"`
+	Sitemaps []sitemapLocation `xml:"sitemap"`
+}
+
+// sitemapLocation is a <url> or <sitemap> element
+type sitemapLocation struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapFilter picks the sitemap entries worth scraping
+type sitemapFilter struct {
+	// Pattern matches the URLs to keep, or keeps every URL when nil
+	Pattern *regexp.Regexp
+	// Since drops the entries whose lastmod is older, or keeps every entry when zero.
+	// Entries without a lastmod are always kept.
+	Since time.Time
+}
+
+// sitemapFilterFromFlags builds the sitemap filter from the command line flags
+func sitemapFilterFromFlags(cmd *cobra.Command) (sitemapFilter, error) {
+	pattern, _ := cmd.Flags().GetString("url-pattern")
+	since, _ := cmd.Flags().GetString("since")
+
+	var filter sitemapFilter
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --url-pattern: %w", err)
+		}
+		filter.Pattern = re
+	}
+	if since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = t
+	}
+	return filter, nil
+}
+
+// parseSince reads --since as a date, a timestamp, or a duration back from now such as 168h or 7d
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if t, ok := parseLastMod(since); ok {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(since, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(since); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: expected a date (2006-01-02), a timestamp or a duration such as 7d", since)
+}
+
+// parseLastMod parses a W3C datetime as used by <lastmod>
+func parseLastMod(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// keep reports whether an entry with the given URL and lastmod passes the filter
+func (f sitemapFilter) keep(pageURL string, lastMod time.Time) bool {
+	if f.Pattern != nil && !f.Pattern.MatchString(pageURL) {
+		return false
+	}
+	return f.Since.IsZero() || lastMod.IsZero() || !lastMod.Before(f.Since)
+}
+
+// sitemapPages returns the pages listed in the --sitemap that pass --url-pattern and --since,
+// or nil when no sitemap was given. Invalid page locations are reported to msg and left out.
+func sitemapPages(cmd *cobra.Command, msg io.Writer) ([]sitemapEntry, error) {
+	sitemapURL, _ := cmd.Flags().GetString("sitemap")
+	if sitemapURL == "" {
+		return nil, nil
+	}
+	filter, err := sitemapFilterFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return readSitemap(sitemapURL, filter, msg)
+}
+
+// readSitemap fetches the sitemap at sitemapURL, following nested sitemap indexes, and returns its
+// entries that pass the filter, without duplicates. A malformed page location is reported to msg and
+// skipped so it doesn't cost the rest of the sitemap.
+func readSitemap(sitemapURL string, filter sitemapFilter, msg io.Writer) ([]sitemapEntry, error) {
+	var entries []sitemapEntry
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+
+	var read func(string, int) error
+	read = func(sitemapURL string, depth int) error {
+		if visited[sitemapURL] {
+			return nil
+		}
+		if depth > maxSitemapDepth {
+			return fmt.Errorf("sitemap indexes nest more than %d deep at %s", maxSitemapDepth, sitemapURL)
+		}
+		visited[sitemapURL] = true
+
+		doc, err := fetchSitemap(sitemapURL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sitemapURL, err)
+		}
+		base, _ := url.Parse(sitemapURL)
+		for _, location := range doc.Sitemaps {
+			child, err := base.Parse(strings.TrimSpace(location.Loc))
+			if err != nil {
+				return fmt.Errorf("%s: invalid sitemap location %q", sitemapURL, location.Loc)
+			}
+			// A nested sitemap that has not changed since --since cannot list pages that have
+			if lastMod, ok := parseLastMod(location.LastMod); ok && !filter.Since.IsZero() && lastMod.Before(filter.Since) {
+				continue
+			}
+			if err := read(child.String(), depth+1); err != nil {
+				return err
+			}
+		}
+		for _, location := range doc.URLs {
+			pageURL, err := parsePageURL(location.Loc)
+			if err != nil {
+				fmt.Fprintf(msg, "Skipped sitemap entry in %s: %v\n", sitemapURL, err)
+				continue
+			}
+			lastMod, _ := parseLastMod(location.LastMod)
+			if seen[pageURL] || !filter.keep(pageURL, lastMod) {
+				continue
+			}
+			seen[pageURL] = true
+			_, dateErr := time.Parse(lastModDateLayout, strings.TrimSpace(location.LastMod))
+			entries = append(entries, sitemapEntry{URL: pageURL, LastMod: lastMod, DateOnly: dateErr == nil})
+		}
+		return nil
+	}
+
+	if err := read(sitemapURL, 0); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// fetchSitemap requests the sitemap at sitemapURL and decodes it, unzipping gzipped sitemaps
+func fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	res, err := http.Get(sitemapURL) //#nosec G107
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to fetch the sitemap (%s)", res.Status)
+	}
+
+	// Gzipped sitemaps (sitemap.xml.gz) are served as files rather than with a Content-Encoding,
+	// so they are recognised by their magic number
+	body := bufio.NewReader(res.Body)
+	var r io.Reader = body
+	if magic, err := body.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding sitemap: %w", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("not a sitemap: root element is <%s>", doc.XMLName.Local)
+	}
+	return &doc, nil
+}
+
+// unchangedPages returns the pages of a sitemap whose lastmod is older than their snapshot in the state
+// file, keyed by URL, with the flashcards they produced last time. They need not be fetched again.
+// A date-only lastmod may hide a change later that day, so it must predate the snapshot's day.
+func unchangedPages(stateFile string, entries []sitemapEntry, options extractOptions) (map[string]pageScrape, error) {
+	if stateFile == "" {
+		return nil, nil
+	}
+	state, err := loadScrapeState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := map[string]pageScrape{}
+	for _, entry := range entries {
+		snapshot, ok := state.Scrapes[pageStateKey(entry.URL, options)]
+		if !ok || entry.LastMod.IsZero() {
+			continue
+		}
+		if entry.DateOnly {
+			// Truncating to a day from the zero time cuts at midnight UTC, the same day boundary as the lastmod
+			if !entry.LastMod.Before(snapshot.Updated.UTC().Truncate(24 * time.Hour)) {
+				continue
+			}
+		} else if entry.LastMod.After(snapshot.Updated) {
+			continue
+		}
+		unchanged[entry.URL] = pageScrape{URL: entry.URL, Title: snapshot.Title, Flashcards: snapshot.Flashcards}
+	}
+	return unchanged, nil
+}
+
+// appendEntryURLs adds the URLs of the sitemap entries to urls, leaving out the ones already there
+func appendEntryURLs(urls []string, entries []sitemapEntry) []string {
+	seen := map[string]bool{}
+	for _, pageURL := range urls {
+		seen[pageURL] = true
+	}
+	for _, entry := range entries {
+		if !seen[entry.URL] {
+			seen[entry.URL] = true
+			urls = append(urls, entry.URL)
+		}
+	}
+	return urls
+}
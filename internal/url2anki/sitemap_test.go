@@ -0,0 +1,128 @@
+package url2anki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadSitemap tests that nested and gzipped sitemaps are read, their entries filtered by URL pattern
+// and lastmod, and malformed locations skipped and reported
+func TestReadSitemap(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/docs.xml.gz</loc><lastmod>2026-09-01</lastmod></sitemap>
+  <sitemap><loc>/old.xml</loc><lastmod>2025-01-01</lastmod></sitemap>
+</sitemapindex>`, server.URL)
+		case "/docs.xml.gz":
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			fmt.Fprintf(gz, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%[1]s/docs/glossary/api</loc><lastmod>2026-09-01T10:00:00+00:00</lastmod></url>
+  <url><loc>%[1]s/docs/glossary/boot</loc><lastmod>2026-02-01</lastmod></url>
+  <url><loc>%[1]s/docs/glossary/cache</loc></url>
+  <url><loc>not a url /docs/glossary/</loc></url>
+  <url><loc>%[1]s/blog/news</loc><lastmod>2026-09-01</lastmod></url>
+</urlset>`, server.URL)
+			_ = gz.Close()
+			_, _ = w.Write(buf.Bytes())
+		default:
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	filter := sitemapFilter{
+		Pattern: regexp.MustCompile(`/docs/glossary/`),
+		Since:   time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var msg strings.Builder
+	entries, err := readSitemap(server.URL+"/sitemap.xml", filter, &msg)
+	if err != nil {
+		t.Fatalf("readSitemap returned an error: %v", err)
+	}
+	if !strings.Contains(msg.String(), `invalid URL "not a url /docs/glossary/"`) {
+		t.Errorf("Expected the malformed location to be reported, got %q", msg.String())
+	}
+
+	var urls []string
+	for _, entry := range entries {
+		urls = append(urls, strings.TrimPrefix(entry.URL, server.URL))
+	}
+	if strings.Join(urls, " ") != "/docs/glossary/api /docs/glossary/cache" {
+		t.Errorf("Expected the recently changed glossary pages and the one without lastmod, got %v", urls)
+	}
+	if !entries[0].LastMod.Equal(time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)) || entries[0].DateOnly {
+		t.Errorf("Unexpected lastmod %v", entries[0].LastMod)
+	}
+}
+
+// TestParseSince tests the date and duration forms of --since
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2026-10-01": time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		"7d":         now.AddDate(0, 0, -7),
+		"36h":        now.Add(-36 * time.Hour),
+	}
+	for input, expected := range tests {
+		got, err := parseSince(input, now)
+		if err != nil || !got.Equal(expected) {
+			t.Errorf("parseSince(%q) = %v, %v; expected %v", input, got, err, expected)
+		}
+	}
+	if _, err := parseSince("last week", now); err == nil {
+		t.Error("Expected an error for an unparseable --since")
+	}
+}
+
+// TestUnchangedPages tests that pages whose lastmod predates their snapshot keep their previous cards, a
+// date-only lastmod only when it predates the snapshot's day
+func TestUnchangedPages(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	options := extractOptions{Mode: selectorsMode, QuestionSelector: "dt", AnswerSelector: "dd"}
+	updated := time.Date(2026, 9, 1, 9, 30, 0, 0, time.UTC)
+	day := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	state := &scrapeState{Version: stateFileVersion, Scrapes: map[string]scrapeSnapshot{}}
+	for _, pageURL := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/d", "https://example.com/e"} {
+		state.Scrapes[pageStateKey(pageURL, options)] = scrapeSnapshot{
+			URL:        pageURL,
+			Title:      "Glossary",
+			Updated:    updated,
+			Flashcards: []Flashcard{{Question: "Q " + pageURL, Answer: "A"}},
+		}
+	}
+	if err := state.save(stateFile); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	entries := []sitemapEntry{
+		{URL: "https://example.com/a", LastMod: updated.AddDate(0, 0, -1)},
+		{URL: "https://example.com/b", LastMod: updated.AddDate(0, 0, 1)},
+		{URL: "https://example.com/c", LastMod: updated.AddDate(0, 0, -1)},
+		{URL: "https://example.com/d", LastMod: day, DateOnly: true},
+		{URL: "https://example.com/e", LastMod: day.AddDate(0, 0, -1), DateOnly: true},
+	}
+	unchanged, err := unchangedPages(stateFile, entries, options)
+	if err != nil {
+		t.Fatalf("unchangedPages returned an error: %v", err)
+	}
+	if _, ok := unchanged["https://example.com/d"]; ok {
+		t.Error("Expected a page changed on the snapshot's day to be refetched")
+	}
+	if len(unchanged) != 2 || unchanged["https://example.com/a"].Flashcards[0].Question != "Q https://example.com/a" {
+		t.Errorf("Expected only the page unchanged since its snapshot to be kept, got %+v", unchanged)
+	}
+}
@@ -0,0 +1,203 @@
+package url2anki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	// stateFileVersion is bumped whenever the state file layout changes incompatibly
+	stateFileVersion = 1
+	// changedTag marks cards whose answer changed upstream since the previous run
+	changedTag = "url2anki::changed"
+	// removedTag marks cards that disappeared upstream since the previous run
+	removedTag = "url2anki::removed"
+)
+
+// reviewTags are the tags url2anki manages on existing notes: they are added and removed to match the latest run
+var reviewTags = []string{changedTag, removedTag}
+
+// scrapeState is the on-disk record of the cards each URL and selector combination produced last time
+type scrapeState struct {
+	Version int                       `json:"version"`
+	Scrapes map[string]scrapeSnapshot `json:"scrapes"`
+}
+
+// scrapeSnapshot is the set of cards a single URL and selector combination produced on its last run
+type scrapeSnapshot struct {
+	URL              string      `json:"url"`
+	Title            string      `json:"title,omitempty"`
+	QuestionSelector string      `json:"questionSelector"`
+	AnswerSelector   string      `json:"answerSelector"`
+	Updated          time.Time   `json:"updated"`
+	Flashcards       []Flashcard `json:"flashcards"`
+}
+
+// scrapeDiff classifies the cards of the current run against the previous one
+type scrapeDiff struct {
+	Added     []Flashcard
+	Changed   []Flashcard
+	Removed   []Flashcard
+	Unchanged []Flashcard
+}
+
+// loadScrapeState reads the state file at path, returning an empty state when it does not exist yet
+func loadScrapeState(path string) (*scrapeState, error) {
+	state := &scrapeState{Version: stateFileVersion, Scrapes: map[string]scrapeSnapshot{}}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path from user CLI arg, expected
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("decoding state file %s: %w", path, err)
+	}
+	if state.Version != stateFileVersion {
+		return nil, fmt.Errorf("state file %s has unsupported version %d", path, state.Version)
+	}
+	if state.Scrapes == nil {
+		state.Scrapes = map[string]scrapeSnapshot{}
+	}
+	return state, nil
+}
+
+// save writes the state file to path
+func (s *scrapeState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600) // #nosec G304 G703 -- path from user CLI arg, expected
+}
+
+// scrapeStateKey identifies a URL and selector combination, plus any extra settings such as the mode, within the state file
+func scrapeStateKey(url, questionSelector, answerSelector string, extra ...string) string {
+	return guidFor(append([]string{normalizeSourceURL(url), questionSelector, answerSelector}, extra...)...)
+}
+
+// diffFlashcards compares the current cards against the previous run's by GUID
+func diffFlashcards(previous, current []Flashcard) scrapeDiff {
+	var diff scrapeDiff
+
+	before := map[string]Flashcard{}
+	for _, flashcard := range previous {
+		before[noteGUID(flashcard)] = flashcard
+	}
+
+	seen := map[string]bool{}
+	for _, flashcard := range current {
+		guid := noteGUID(flashcard)
+		seen[guid] = true
+		old, ok := before[guid]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, flashcard)
+		case old.Answer != flashcard.Answer:
+			diff.Changed = append(diff.Changed, flashcard)
+		default:
+			diff.Unchanged = append(diff.Unchanged, flashcard)
+		}
+	}
+
+	for _, flashcard := range previous {
+		if !seen[noteGUID(flashcard)] {
+			diff.Removed = append(diff.Removed, flashcard)
+		}
+	}
+
+	return diff
+}
+
+// deltaFlashcards returns only the added and changed cards.
+// When tagChanges is set, changed cards are tagged and the tagged removed cards are included too.
+func (d scrapeDiff) deltaFlashcards(tagChanges bool) []Flashcard {
+	delta := append([]Flashcard{}, d.Added...)
+	for _, flashcard := range d.Changed {
+		if tagChanges {
+			flashcard = withTag(flashcard, changedTag)
+		}
+		delta = append(delta, flashcard)
+	}
+	if tagChanges {
+		for _, flashcard := range d.Removed {
+			delta = append(delta, withTag(flashcard, removedTag))
+		}
+	}
+	return delta
+}
+
+// tagFlashcards returns every current card with changed cards tagged, followed by the tagged removed cards
+// so Anki can find and suspend them. Removed cards only ever tag notes that already exist, see isRemovedFlashcard.
+func (d scrapeDiff) tagFlashcards(current []Flashcard) []Flashcard {
+	changed := map[string]bool{}
+	for _, flashcard := range d.Changed {
+		changed[noteGUID(flashcard)] = true
+	}
+
+	var tagged []Flashcard
+	for _, flashcard := range current {
+		if changed[noteGUID(flashcard)] {
+			flashcard = withTag(flashcard, changedTag)
+		}
+		tagged = append(tagged, flashcard)
+	}
+	for _, flashcard := range d.Removed {
+		tagged = append(tagged, withTag(flashcard, removedTag))
+	}
+	return tagged
+}
+
+// isRemovedFlashcard reports whether the flashcard is a card removed upstream, which is delivered only as a
+// tag on its existing note and never exported or added as a new note
+func isRemovedFlashcard(flashcard Flashcard) bool {
+	return slices.Contains(flashcard.Tags, removedTag)
+}
+
+// withTag returns a copy of the flashcard with tag added
+func withTag(flashcard Flashcard, tag string) Flashcard {
+	flashcard.Tags = append(append([]string{}, flashcard.Tags...), tag)
+	return flashcard
+}
+
+// printScrapeDiff prints a colored summary of the diff followed by each added, changed and removed card
+func printScrapeDiff(w io.Writer, d scrapeDiff, color bool) {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return "\033[" + code + "m" + s + "\033[0m"
+	}
+
+	fmt.Fprintf(w, "%s, %s, %s, %d unchanged\n",
+		paint("32", fmt.Sprintf("%d added", len(d.Added))),
+		paint("33", fmt.Sprintf("%d changed", len(d.Changed))),
+		paint("31", fmt.Sprintf("%d removed", len(d.Removed))),
+		len(d.Unchanged),
+	)
+	for _, flashcard := range d.Added {
+		fmt.Fprintln(w, paint("32", "+ "+flashcard.Question))
+	}
+	for _, flashcard := range d.Changed {
+		fmt.Fprintln(w, paint("33", "~ "+flashcard.Question))
+	}
+	for _, flashcard := range d.Removed {
+		fmt.Fprintln(w, paint("31", "- "+flashcard.Question))
+	}
+}
+
+// useColor reports whether stdout is a terminal that has not opted out of color via NO_COLOR
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,92 @@
+package url2anki
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestDiffFlashcards tests the diffFlashcards function
+func TestDiffFlashcards(t *testing.T) {
+	previous := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1", GUID: "guid1"},
+		{Question: "Question 2", Answer: "Answer 2", GUID: "guid2"},
+		{Question: "Question 3", Answer: "Answer 3", GUID: "guid3"},
+	}
+	current := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1", GUID: "guid1"},
+		{Question: "Question 2", Answer: "New answer 2", GUID: "guid2"},
+		{Question: "Question 4", Answer: "Answer 4", GUID: "guid4"},
+	}
+
+	diff := diffFlashcards(previous, current)
+	if len(diff.Added) != 1 || diff.Added[0].GUID != "guid4" {
+		t.Errorf("Expected guid4 to be added, got %+v", diff.Added)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].GUID != "guid2" {
+		t.Errorf("Expected guid2 to be changed, got %+v", diff.Changed)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].GUID != "guid3" {
+		t.Errorf("Expected guid3 to be removed, got %+v", diff.Removed)
+	}
+	if len(diff.Unchanged) != 1 || diff.Unchanged[0].GUID != "guid1" {
+		t.Errorf("Expected guid1 to be unchanged, got %+v", diff.Unchanged)
+	}
+
+	// Only the delta is exported, with changed and removed cards tagged on request
+	delta := diff.deltaFlashcards(true)
+	if len(delta) != 3 {
+		t.Fatalf("Expected 3 cards in the delta, got %d", len(delta))
+	}
+	if !slices.Contains(delta[1].Tags, changedTag) || !slices.Contains(delta[2].Tags, removedTag) {
+		t.Errorf("Expected changed and removed cards to be tagged, got %+v", delta)
+	}
+	if len(diff.deltaFlashcards(false)) != 2 {
+		t.Errorf("Expected removed cards to be left out of an untagged delta")
+	}
+
+	tagged := diff.tagFlashcards(current)
+	if len(tagged) != 4 || len(tagged[0].Tags) != 0 || !slices.Contains(tagged[1].Tags, changedTag) {
+		t.Errorf("Expected every card with only the changed one tagged, got %+v", tagged)
+	}
+	if len(current[1].Tags) != 0 {
+		t.Errorf("Expected tagging not to modify the scraped cards")
+	}
+
+	var out bytes.Buffer
+	printScrapeDiff(&out, diff, false)
+	if !strings.HasPrefix(out.String(), "1 added, 1 changed, 1 removed, 1 unchanged\n") {
+		t.Errorf("Unexpected summary %q", out.String())
+	}
+}
+
+// TestScrapeStateRoundTrip tests that a saved state file is loaded back unchanged
+func TestScrapeStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadScrapeState(path)
+	if err != nil {
+		t.Fatalf("loadScrapeState returned an error for a missing file: %v", err)
+	}
+	key := scrapeStateKey("https://example.com/glossary", "dt", "dd")
+	state.Scrapes[key] = scrapeSnapshot{
+		URL:        "https://example.com/glossary",
+		Flashcards: []Flashcard{{Question: "Question 1", Answer: "Answer 1", GUID: "guid1"}},
+	}
+	if err := state.save(path); err != nil {
+		t.Fatalf("save returned an error: %v", err)
+	}
+
+	loaded, err := loadScrapeState(path)
+	if err != nil {
+		t.Fatalf("loadScrapeState returned an error: %v", err)
+	}
+	if cards := loaded.Scrapes[key].Flashcards; len(cards) != 1 || cards[0].GUID != "guid1" {
+		t.Errorf("Expected the saved card back, got %+v", cards)
+	}
+	if scrapeStateKey("https://example.com/glossary", "dt", "dd.other") == key {
+		t.Errorf("Expected different selectors to use a different state key")
+	}
+}
@@ -0,0 +1,238 @@
+package url2anki
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// stdoutFilename is the output filename that streams JSON Lines cards to stdout
+const stdoutFilename = "-"
+
+// maxStreamLine bounds a single JSON Lines record, generous enough for long HTML answers
+const maxStreamLine = 16 * 1024 * 1024
+
+// readFlashcardStream reads newline-delimited JSON Flashcard records, skipping blank lines
+func readFlashcardStream(r io.Reader) ([]Flashcard, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+
+	var flashcards []Flashcard
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var flashcard Flashcard
+		if err := json.Unmarshal([]byte(text), &flashcard); err != nil {
+			return nil, fmt.Errorf("reading flashcard on line %d: %w", line, err)
+		}
+		flashcards = append(flashcards, flashcard)
+	}
+	return flashcards, scanner.Err()
+}
+
+// writeFlashcardStream writes the flashcards as newline-delimited JSON records
+func writeFlashcardStream(w io.Writer, flashcards []Flashcard) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, flashcard := range flashcards {
+		if err := encoder.Encode(flashcard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFlashcardsToJSONLinesFile writes the flashcards as JSON Lines to filename, or to stdout for "-"
+func exportFlashcardsToJSONLinesFile(flashcards []Flashcard, filename string) error {
+	if filename == stdoutFilename {
+		return writeFlashcardStream(os.Stdout, flashcards)
+	}
+
+	file, err := os.Create(filename) //#nosec G304
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := writeFlashcardStream(writer, flashcards); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// jsonLinesExporter writes flashcards as newline-delimited JSON, the format the stream subcommands pipe
+type jsonLinesExporter struct{}
+
+func (jsonLinesExporter) Name() string         { return "jsonl" }
+func (jsonLinesExporter) Extensions() []string { return []string{".jsonl", ".ndjson"} }
+func (jsonLinesExporter) Export(flashcards []Flashcard, options ExportOptions, filename string) error {
+	return exportFlashcardsToJSONLinesFile(flashcards, filename)
+}
+
+// Filter is the entry point of the filter subcommand, which drops cards from a JSON Lines stream
+func Filter(cmd *cobra.Command, args []string) error {
+	minLen, _ := cmd.Flags().GetInt("min-len")
+	maxLen, _ := cmd.Flags().GetInt("max-len")
+	match, _ := cmd.Flags().GetString("match")
+	notMatch, _ := cmd.Flags().GetString("not-match")
+	tags, _ := cmd.Flags().GetStringArray("tag")
+	dedupe, _ := cmd.Flags().GetBool("dedupe")
+
+	keep, err := newFlashcardFilter(minLen, maxLen, match, notMatch, tags, dedupe)
+	if err != nil {
+		return err
+	}
+
+	flashcards, err := readFlashcardStream(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	var kept []Flashcard
+	for _, flashcard := range flashcards {
+		if keep(flashcard) {
+			kept = append(kept, flashcard)
+		}
+	}
+	return writeFlashcardStream(cmd.OutOrStdout(), kept)
+}
+
+// newFlashcardFilter returns a predicate keeping cards whose answer length is within [minLen, maxLen],
+// that match and do not match the given patterns, carry every tag and, with dedupe, were not seen before
+func newFlashcardFilter(minLen, maxLen int, match, notMatch string, tags []string, dedupe bool) (func(Flashcard) bool, error) {
+	var matchRe, notMatchRe *regexp.Regexp
+	var err error
+	if match != "" {
+		if matchRe, err = regexp.Compile(match); err != nil {
+			return nil, fmt.Errorf("invalid --match pattern: %w", err)
+		}
+	}
+	if notMatch != "" {
+		if notMatchRe, err = regexp.Compile(notMatch); err != nil {
+			return nil, fmt.Errorf("invalid --not-match pattern: %w", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	return func(flashcard Flashcard) bool {
+		length := utf8.RuneCountInString(flashcard.Answer)
+		if length < minLen || (maxLen > 0 && length > maxLen) {
+			return false
+		}
+		text := flashcard.Question + "\n" + flashcard.Answer
+		if matchRe != nil && !matchRe.MatchString(text) {
+			return false
+		}
+		if notMatchRe != nil && notMatchRe.MatchString(text) {
+			return false
+		}
+		for _, tag := range tags {
+			if !slices.Contains(flashcard.Tags, tag) {
+				return false
+			}
+		}
+		if dedupe {
+			key := mergeKey(flashcard)
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+		}
+		return true
+	}, nil
+}
+
+// Transform is the entry point of the transform subcommand, which rewrites cards in a JSON Lines stream
+func Transform(cmd *cobra.Command, args []string) error {
+	addTags, _ := cmd.Flags().GetStringArray("add-tag")
+	swap, _ := cmd.Flags().GetBool("swap")
+	replacements, _ := cmd.Flags().GetStringArray("replace")
+	truncate, _ := cmd.Flags().GetInt("truncate")
+
+	transform, err := newFlashcardTransform(addTags, swap, replacements, truncate)
+	if err != nil {
+		return err
+	}
+
+	flashcards, err := readFlashcardStream(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	for i := range flashcards {
+		flashcards[i] = transform(flashcards[i])
+	}
+	return writeFlashcardStream(cmd.OutOrStdout(), flashcards)
+}
+
+// newFlashcardTransform returns a function applying the regex replacements (written "pattern=replacement"),
+// truncating answers to truncate characters, swapping question and answer and adding tags, in that order
+func newFlashcardTransform(addTags []string, swap bool, replacements []string, truncate int) (func(Flashcard) Flashcard, error) {
+	type replacement struct {
+		pattern *regexp.Regexp
+		with    string
+	}
+	var rules []replacement
+	for _, r := range replacements {
+		pattern, with, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --replace %q: expected pattern=replacement", r)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --replace pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, replacement{pattern: re, with: with})
+	}
+
+	return func(flashcard Flashcard) Flashcard {
+		for _, rule := range rules {
+			flashcard.Question = rule.pattern.ReplaceAllString(flashcard.Question, rule.with)
+			flashcard.Answer = rule.pattern.ReplaceAllString(flashcard.Answer, rule.with)
+		}
+		if truncate > 0 && utf8.RuneCountInString(flashcard.Answer) > truncate {
+			flashcard.Answer = strings.TrimSpace(string([]rune(flashcard.Answer)[:truncate])) + "…"
+		}
+		if swap {
+			flashcard.Question, flashcard.Answer = flashcard.Answer, flashcard.Question
+		}
+		for _, tag := range addTags {
+			if !slices.Contains(flashcard.Tags, tag) {
+				flashcard = withTag(flashcard, tag)
+			}
+		}
+		return flashcard
+	}, nil
+}
+
+// Export is the entry point of the export subcommand, which delivers a JSON Lines stream to files and Anki
+func Export(cmd *cobra.Command, args []string) error {
+	deckName, _ := cmd.Flags().GetString("deck")
+	if deckName == "" {
+		deckName = "Default"
+	}
+
+	outputs, err := resolveOutputs(cmd)
+	if err != nil {
+		return err
+	}
+	flashcards, err := readFlashcardStream(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	// An empty stream usually means an earlier stage failed, so existing decks are not overwritten with nothing
+	if allowEmpty, _ := cmd.Flags().GetBool("allow-empty"); len(flashcards) == 0 && !allowEmpty {
+		return errors.New("the card stream is empty, pass --allow-empty to export it anyway")
+	}
+	return deliverFlashcards(cmd, messageWriter(outputs), outputs, flashcards, deckName)
+}
@@ -0,0 +1,141 @@
+package url2anki
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestFlashcardStreamRoundTrip tests that writeFlashcardStream output reads back unchanged
+func TestFlashcardStreamRoundTrip(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "<b>Answer</b> 1", GUID: "abc", Tags: []string{"k8s"}},
+		{Question: "Question 2", Answer: "Answer 2"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeFlashcardStream(&buf, flashcards); err != nil {
+		t.Fatalf("writeFlashcardStream returned an error: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(flashcards) {
+		t.Errorf("Expected %d lines, got %d", len(flashcards), lines)
+	}
+	if !strings.Contains(buf.String(), "<b>") {
+		t.Errorf("Expected HTML to be written unescaped, got %s", buf.String())
+	}
+
+	got, err := readFlashcardStream(strings.NewReader(buf.String() + "\n\n"))
+	if err != nil {
+		t.Fatalf("readFlashcardStream returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(got, flashcards) {
+		t.Errorf("Expected %+v, got %+v", flashcards, got)
+	}
+
+	if _, err := readFlashcardStream(strings.NewReader("{\"question\":\"Q\"}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error naming line 2, got %v", err)
+	}
+}
+
+// TestFlashcardFilter tests the newFlashcardFilter function
+func TestFlashcardFilter(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Pod", Answer: "The smallest deployable unit", Tags: []string{"k8s"}},
+		{Question: "Node", Answer: "A machine", Tags: []string{"k8s"}},
+		{Question: "PodSecurityPolicy", Answer: "Deprecated admission controller"},
+		{Question: "Pod", Answer: "The smallest deployable unit again", Tags: []string{"k8s"}},
+	}
+
+	keep, err := newFlashcardFilter(10, 0, "", "(?i)deprecated", []string{"k8s"}, true)
+	if err != nil {
+		t.Fatalf("newFlashcardFilter returned an error: %v", err)
+	}
+	var kept []string
+	for _, flashcard := range flashcards {
+		if keep(flashcard) {
+			kept = append(kept, flashcard.Answer)
+		}
+	}
+	if expected := []string{"The smallest deployable unit"}; !reflect.DeepEqual(kept, expected) {
+		t.Errorf("Expected %v, got %v", expected, kept)
+	}
+
+	if _, err := newFlashcardFilter(0, 0, "(", "", nil, false); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
+
+// TestTransform tests the transform subcommand end to end over a stream
+func TestTransform(t *testing.T) {
+	cmd := &cobra.Command{RunE: Transform}
+	cmd.Flags().StringArray("add-tag", nil, "")
+	cmd.Flags().Bool("swap", false, "")
+	cmd.Flags().StringArray("replace", nil, "")
+	cmd.Flags().Int("truncate", 0, "")
+	_ = cmd.Flags().Set("add-tag", "glossary")
+	_ = cmd.Flags().Set("replace", `\s*\[\d+\]=`)
+	_ = cmd.Flags().Set("truncate", "9")
+	_ = cmd.Flags().Set("swap", "true")
+
+	var out bytes.Buffer
+	cmd.SetIn(strings.NewReader(`{"question":"Pod","answer":"The smallest [1] deployable unit"}` + "\n"))
+	cmd.SetOut(&out)
+	if err := Transform(cmd, nil); err != nil {
+		t.Fatalf("Transform returned an error: %v", err)
+	}
+
+	got, err := readFlashcardStream(&out)
+	if err != nil {
+		t.Fatalf("Failed to read the output stream: %v", err)
+	}
+	expected := []Flashcard{{Question: "The small…", Answer: "Pod", Tags: []string{"glossary"}}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+// TestExporterForStdout tests that only the card stream can be written to stdout
+func TestExporterForStdout(t *testing.T) {
+	exporter, err := exporterFor(stdoutFilename, "")
+	if err != nil || exporter.Name() != "jsonl" {
+		t.Errorf("Expected the jsonl exporter for stdout, got %v, %v", exporter, err)
+	}
+	if _, err := exporterFor(stdoutFilename, "apkg"); err == nil {
+		t.Error("Expected apkg to stdout to be rejected")
+	}
+}
+
+// TestExportEmptyStream tests that export refuses an empty stream unless --allow-empty is given
+func TestExportEmptyStream(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "deck.json")
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("output-file", "o", "", "")
+		cmd.Flags().Bool("allow-empty", false, "")
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		cmd.SetIn(strings.NewReader(""))
+		return cmd
+	}
+
+	if err := Export(newCmd("-o", output), nil); err == nil {
+		t.Errorf("Expected an empty stream to be refused")
+	}
+	if _, err := os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected %s not to be written, got %v", output, err)
+	}
+
+	if err := Export(newCmd("-o", output, "--allow-empty"), nil); err != nil {
+		t.Fatalf("Export with --allow-empty returned an error: %v", err)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("Expected %s to be written: %v", output, err)
+	}
+}
@@ -0,0 +1,211 @@
+package url2anki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/toozej/url2anki/pkg/version"
+)
+
+const (
+	// ankiSyncVersion is the sync protocol version url2anki speaks, which posts zstd-compressed bodies directly
+	ankiSyncVersion = 11
+	// ankiSyncHeader carries the protocol version, host key and session on every sync request
+	ankiSyncHeader = "anki-sync"
+)
+
+// ankiSyncClient talks to an Anki sync server (AnkiWeb or a self-hosted anki-sync-server)
+type ankiSyncClient struct {
+	endpoint   string
+	httpClient *http.Client
+	hostKey    string
+	session    string
+}
+
+// newAnkiSyncClient returns a client for the sync server at endpoint (EX: http://127.0.0.1:8080/)
+func newAnkiSyncClient(endpoint string) *ankiSyncClient {
+	session := make([]byte, 8)
+	_, _ = rand.Read(session)
+	return &ankiSyncClient{
+		endpoint:   strings.TrimSuffix(endpoint, "/") + "/",
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		session:    hex.EncodeToString(session),
+	}
+}
+
+// request posts a zstd-compressed body to the sync method and returns the decompressed response
+func (c *ankiSyncClient) request(method string, body []byte) ([]byte, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	compressed := encoder.EncodeAll(body, nil)
+	encoder.Close()
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint+"sync/"+method, bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(map[string]any{
+		"v": ankiSyncVersion,
+		"k": c.hostKey,
+		"c": "url2anki," + version.Version,
+		"s": c.session,
+	})
+	req.Header.Set(ankiSyncHeader, string(header))
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sync %s: unexpected status %s: %s", method, res.Status, strings.TrimSpace(string(data)))
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	return decoder.DecodeAll(data, nil)
+}
+
+// login exchanges the username and password for the host key used by every later request
+func (c *ankiSyncClient) login(username, password string) error {
+	body, _ := json.Marshal(map[string]string{"u": username, "p": password})
+	data, err := c.request("hostKey", body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("sync hostKey: %w", err)
+	}
+	if response.Key == "" {
+		return fmt.Errorf("sync hostKey: the server did not return a host key")
+	}
+	c.hostKey = response.Key
+	return nil
+}
+
+// ankiSyncMeta is the server's view of the collection as returned by the meta sync method
+type ankiSyncMeta struct {
+	Modified int64 `json:"mod"`
+	Schema   int64 `json:"scm"`
+	USN      int64 `json:"usn"`
+}
+
+// meta returns the modification time and update sequence number of the server's collection
+func (c *ankiSyncClient) meta() (ankiSyncMeta, error) {
+	body, _ := json.Marshal(map[string]any{"v": ankiSyncVersion, "cv": "url2anki," + version.Version})
+	data, err := c.request("meta", body)
+	if err != nil {
+		return ankiSyncMeta{}, err
+	}
+	var meta ankiSyncMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return ankiSyncMeta{}, fmt.Errorf("sync meta: %w", err)
+	}
+	return meta, nil
+}
+
+// download fetches the server's copy of the collection and writes it to path
+func (c *ankiSyncClient) download(path string) error {
+	data, err := c.request("download", []byte("{}"))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600) // #nosec G703 -- path is a scratch file url2anki created
+}
+
+// upload replaces the server's copy of the collection with the collection at path
+func (c *ankiSyncClient) upload(path string) error {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is a scratch file url2anki created
+	if err != nil {
+		return err
+	}
+	response, err := c.request("upload", data)
+	if err != nil {
+		return err
+	}
+	if string(response) != "OK" {
+		return fmt.Errorf("sync upload: server responded %q", response)
+	}
+	return nil
+}
+
+// syncFlashcardsToServer downloads the user's collection, upserts the request's flashcards into its deck
+// and uploads the result so every device picks the cards up on its next normal sync. The upload replaces
+// the server's whole collection, so it is refused when the server's collection no longer matches the
+// download; a device syncing between that check and the upload still loses its changes, which is why
+// callers must opt in with --force-full-upload.
+func syncFlashcardsToServer(client *ankiSyncClient, username, password string, request AnkiSyncRequest) (collectionReport, error) {
+	var report collectionReport
+
+	if err := client.login(username, password); err != nil {
+		return report, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "url2anki-sync")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Remember the server's state before downloading, so changes made meanwhile are not overwritten
+	before, err := client.meta()
+	if err != nil {
+		return report, err
+	}
+	collectionPath := filepath.Join(tmpDir, "collection.anki2")
+	if err := client.download(collectionPath); err != nil {
+		return report, err
+	}
+
+	collection, err := openAnkiCollection(collectionPath)
+	if err != nil {
+		return report, err
+	}
+	report, err = collection.addFlashcards(request.DeckName, request.Flashcards)
+	if err == nil {
+		err = collection.prepareForUpload()
+	}
+	if err != nil {
+		collection.discard()
+		return report, err
+	}
+	if err := collection.Close(); err != nil {
+		return report, err
+	}
+
+	after, err := client.meta()
+	if err != nil {
+		return report, err
+	}
+	if after.Modified != before.Modified || after.USN != before.USN {
+		return report, errors.New("the collection on the sync server changed while url2anki was updating it; sync your devices and run again")
+	}
+	return report, client.upload(collectionPath)
+}
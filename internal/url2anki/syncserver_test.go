@@ -0,0 +1,280 @@
+package url2anki
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// fakeSyncServer is a stand-in for anki-sync-server holding a single user's collection
+type fakeSyncServer struct {
+	collection []byte
+	uploads    int
+	modified   int64
+	usn        int64
+	// onDownload runs after the collection was downloaded, to simulate another device syncing meanwhile
+	onDownload func()
+}
+
+// serveHTTP implements the hostKey, meta, download and upload sync methods
+func (f *fakeSyncServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var header struct {
+		Version int    `json:"v"`
+		Key     string `json:"k"`
+	}
+	if err := json.Unmarshal([]byte(r.Header.Get(ankiSyncHeader)), &header); err != nil || header.Version != ankiSyncVersion {
+		http.Error(w, "bad sync header", http.StatusBadRequest)
+		return
+	}
+
+	decoder, _ := zstd.NewReader(r.Body)
+	defer decoder.Close()
+	body, err := io.ReadAll(decoder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var response []byte
+	switch strings.TrimPrefix(r.URL.Path, "/sync/") {
+	case "hostKey":
+		var login struct{ U, P string }
+		_ = json.Unmarshal(body, &login)
+		if login.U != "user" || login.P != "pass" {
+			http.Error(w, "invalid credentials", http.StatusForbidden)
+			return
+		}
+		response = []byte(`{"key":"secret"}`)
+	case "meta":
+		if header.Key != "secret" {
+			http.Error(w, "not logged in", http.StatusForbidden)
+			return
+		}
+		response, _ = json.Marshal(ankiSyncMeta{Modified: f.modified, USN: f.usn})
+	case "download":
+		if header.Key != "secret" {
+			http.Error(w, "not logged in", http.StatusForbidden)
+			return
+		}
+		response = f.collection
+		if f.onDownload != nil {
+			f.onDownload()
+		}
+	case "upload":
+		if header.Key != "secret" {
+			http.Error(w, "not logged in", http.StatusForbidden)
+			return
+		}
+		f.collection = body
+		f.uploads++
+		f.modified++
+		response = []byte("OK")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	encoder, _ := zstd.NewWriter(nil)
+	defer encoder.Close()
+	_, _ = w.Write(encoder.EncodeAll(response, nil))
+}
+
+// modernCollectionSchema is a trimmed-down schema 18 collection with a stock Basic note type
+const modernCollectionSchema = `
+CREATE TABLE col (id integer primary key, crt integer, mod integer, scm integer, ver integer, dty integer,
+	usn integer, ls integer, conf text, models text, decks text, dconf text, tags text);
+INSERT INTO col VALUES (1, 0, 0, 0, 18, 0, 0, 0, '', '', '', '', '');
+CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null,
+	flags integer not null, data text not null);
+CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null,
+	ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null,
+	odue integer not null, odid integer not null, flags integer not null, data text not null);
+CREATE TABLE decks (id integer PRIMARY KEY NOT NULL, name text NOT NULL COLLATE unicase, mtime_secs integer NOT NULL,
+	usn integer NOT NULL, common blob NOT NULL, kind blob NOT NULL);
+CREATE UNIQUE INDEX idx_decks_name ON decks (name);
+INSERT INTO decks VALUES (1, 'Default', 0, 0, x'', x'0a020801');
+CREATE TABLE notetypes (id integer NOT NULL PRIMARY KEY, name text NOT NULL COLLATE unicase, mtime_secs integer NOT NULL,
+	usn integer NOT NULL, config blob NOT NULL);
+INSERT INTO notetypes VALUES (42, 'Basic', 0, 0, x'');
+CREATE TABLE fields (ntid integer NOT NULL, ord integer NOT NULL, name text NOT NULL COLLATE unicase,
+	config blob NOT NULL, PRIMARY KEY (ntid, ord)) without rowid;
+INSERT INTO fields VALUES (42, 0, 'Front', x''), (42, 1, 'Back', x'');
+CREATE TABLE templates (ntid integer NOT NULL, ord integer NOT NULL, name text NOT NULL COLLATE unicase,
+	mtime_secs integer NOT NULL, usn integer NOT NULL, config blob NOT NULL, PRIMARY KEY (ntid, ord)) without rowid;
+INSERT INTO templates VALUES (42, 0, 'Card 1', 0, 0, x'');
+CREATE TABLE config (KEY text NOT NULL PRIMARY KEY, usn integer NOT NULL, mtime_secs integer NOT NULL,
+	val blob NOT NULL) without rowid;
+INSERT INTO config VALUES ('nextPos', 0, 0, CAST('7' AS BLOB));
+`
+
+// TestSyncFlashcardsToServer tests that syncFlashcardsToServer adds and updates notes in legacy and modern collections
+func TestSyncFlashcardsToServer(t *testing.T) {
+	existing := []Flashcard{
+		{Question: "Question 1", Answer: "Old answer"},
+		{Question: "Question 2", Answer: "Answer 2"},
+	}
+	scraped := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1"},
+		{Question: "Question 2", Answer: "Answer 2"},
+		{Question: "Question 3", Answer: "Answer 3"},
+	}
+
+	collections := map[string]func(t *testing.T, path string){
+		"legacy schema": func(t *testing.T, path string) {
+			if err := writeDeckCollection(existing, "Glossary", path); err != nil {
+				t.Fatalf("Failed to create collection: %v", err)
+			}
+		},
+		"modern schema": func(t *testing.T, path string) {
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatalf("Failed to create collection: %v", err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(modernCollectionSchema); err != nil {
+				t.Fatalf("Failed to create collection: %v", err)
+			}
+		},
+	}
+
+	for name, create := range collections {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			seedPath := filepath.Join(dir, "seed.anki2")
+			create(t, seedPath)
+			seed, err := os.ReadFile(seedPath)
+			if err != nil {
+				t.Fatalf("Failed to read collection: %v", err)
+			}
+			seedSchema := collectionSchemaTime(t, seedPath)
+
+			fake := &fakeSyncServer{collection: seed}
+			server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+			defer server.Close()
+
+			request := AnkiSyncRequest{DeckName: "Glossary", Flashcards: scraped}
+			report, err := syncFlashcardsToServer(newAnkiSyncClient(server.URL), "user", "pass", request)
+			if err != nil {
+				t.Fatalf("syncFlashcardsToServer returned an error: %v", err)
+			}
+			if fake.uploads != 1 {
+				t.Fatalf("Expected 1 upload, got %d", fake.uploads)
+			}
+
+			// Modern collections start without the deck, so every card is new
+			expected := collectionReport{Added: 1, Updated: 1, Unchanged: 1}
+			if name == "modern schema" {
+				expected = collectionReport{Added: 3}
+			}
+			if report != expected {
+				t.Errorf("Expected report %+v, got %+v", expected, report)
+			}
+
+			// Read back what the server received
+			uploadedPath := filepath.Join(dir, "uploaded.anki2")
+			if err := os.WriteFile(uploadedPath, fake.collection, 0600); err != nil {
+				t.Fatalf("Failed to write uploaded collection: %v", err)
+			}
+			db, err := sql.Open("sqlite", uploadedPath)
+			if err != nil {
+				t.Fatalf("Failed to open uploaded collection: %v", err)
+			}
+			defer db.Close()
+
+			var count, pending int
+			if err := db.QueryRow("SELECT count(*), sum(usn = -1) FROM notes").Scan(&count, &pending); err != nil {
+				t.Fatalf("Failed to count notes: %v", err)
+			}
+			if count != len(scraped) || pending != 0 {
+				t.Errorf("Expected %d synced notes, got %d with %d pending", len(scraped), count, pending)
+			}
+
+			var flds string
+			if err := db.QueryRow("SELECT flds FROM notes WHERE sfld = 'Question 1'").Scan(&flds); err != nil {
+				t.Fatalf("Failed to read note: %v", err)
+			}
+			if flds != "Question 1"+ankiFieldSeparator+"Answer 1" {
+				t.Errorf("Expected Question 1 to be updated, got %q", flds)
+			}
+
+			// The upload should look like a normal sync: new changes stamped with the server's usn, which
+			// is advanced, and the schema left alone so other devices do not need a full sync
+			var usn, scm int64
+			if err := db.QueryRow("SELECT usn, scm FROM col").Scan(&usn, &scm); err != nil {
+				t.Fatalf("Failed to read collection: %v", err)
+			}
+			if usn != 1 || scm != seedSchema {
+				t.Errorf("Expected usn 1 and scm %d, got usn %d and scm %d", seedSchema, usn, scm)
+			}
+			var stale int
+			if err := db.QueryRow("SELECT count(*) FROM notes WHERE usn != 0").Scan(&stale); err != nil {
+				t.Fatalf("Failed to count notes: %v", err)
+			}
+			if stale != 0 {
+				t.Errorf("Expected every note to carry the server's usn 0, got %d that do not", stale)
+			}
+		})
+	}
+}
+
+// collectionSchemaTime returns the schema modification time of the collection at path
+func collectionSchemaTime(t *testing.T, path string) int64 {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open collection: %v", err)
+	}
+	defer db.Close()
+	var scm int64
+	if err := db.QueryRow("SELECT scm FROM col").Scan(&scm); err != nil {
+		t.Fatalf("Failed to read collection: %v", err)
+	}
+	return scm
+}
+
+// TestSyncFlashcardsToServerConflict tests that the upload is aborted when another device synced
+// between the download and the upload
+func TestSyncFlashcardsToServerConflict(t *testing.T) {
+	seedPath := filepath.Join(t.TempDir(), "seed.anki2")
+	if err := writeDeckCollection([]Flashcard{{Question: "Question 1", Answer: "Answer 1"}}, "Glossary", seedPath); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	seed, err := os.ReadFile(seedPath)
+	if err != nil {
+		t.Fatalf("Failed to read collection: %v", err)
+	}
+
+	fake := &fakeSyncServer{collection: seed, modified: 1000, usn: 5}
+	fake.onDownload = func() { fake.usn++ }
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	defer server.Close()
+
+	request := AnkiSyncRequest{DeckName: "Glossary", Flashcards: []Flashcard{{Question: "Question 2", Answer: "Answer 2"}}}
+	if _, err := syncFlashcardsToServer(newAnkiSyncClient(server.URL), "user", "pass", request); err == nil {
+		t.Fatalf("Expected syncFlashcardsToServer to fail when the server's collection changed")
+	}
+	if fake.uploads != 0 {
+		t.Errorf("Expected no upload, got %d", fake.uploads)
+	}
+}
+
+// TestAnkiSyncClientLoginRejected tests that a failed login is surfaced as an error
+func TestAnkiSyncClientLoginRejected(t *testing.T) {
+	fake := &fakeSyncServer{}
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	defer server.Close()
+
+	if err := newAnkiSyncClient(server.URL).login("user", "wrong"); err == nil {
+		t.Fatalf("Expected login with a wrong password to fail")
+	}
+}
@@ -0,0 +1,280 @@
+package url2anki
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxColspan and maxRowspan clamp cell spans the way browsers do, so a huge span can't exhaust memory
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// tableCell is one slot of a table's layout grid, with spanning cells repeated in every slot they cover
+type tableCell struct {
+	Text   string
+	Header bool
+	// Source is the <td> or <th> the slot came from, shared by every slot a spanning cell covers
+	Source *html.Node
+}
+
+// tableRow is one row of a table's layout grid
+type tableRow struct {
+	Cells []tableCell
+	// Header is set for rows inside <thead> or made only of <th> cells, and Head only for rows inside <thead>
+	Header bool
+	Head   bool
+	Path   string
+}
+
+// extractTables builds one flashcard per body row of every table matched by the table selector, taking the
+// question and answer from the chosen columns. Extra columns are appended to the answer as "Header: value"
+// and tag columns become tags, so every cell of a row can end up on the card.
+func extractTables(doc *goquery.Document, options extractOptions) (extraction, error) {
+	var result extraction
+	tableSelector := options.TableSelector
+	if tableSelector == "" {
+		tableSelector = "table"
+	}
+	if options.QuestionColumn == "" {
+		options.QuestionColumn = "1"
+	}
+	if options.AnswerColumn == "" {
+		options.AnswerColumn = "2"
+	}
+
+	var err error
+	doc.Find(tableSelector).EachWithBreak(func(_ int, table *goquery.Selection) bool {
+		rows := tableGrid(table, options.Exclude)
+		header := tableHeader(rows)
+		var question, answer int
+		var extra, tagCols []int
+		if question, err = tableColumn(options.QuestionColumn, header); err == nil {
+			if answer, err = tableColumn(options.AnswerColumn, header); err == nil {
+				if extra, err = tableColumns(options.ExtraColumns, header); err == nil {
+					tagCols, err = tableColumns(options.TagColumns, header)
+				}
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("table at %s: %w", domPath(table.Get(0)), err)
+			return false
+		}
+
+		index := 0
+		for _, row := range rows {
+			if row.Header {
+				continue
+			}
+			index++
+			flashcard := Flashcard{Question: row.cell(question), Answer: row.cell(answer)}
+			if missing := missingFields(flashcard); missing != "" {
+				result.Skipped = append(result.Skipped, skippedItem{
+					Kind:    "row",
+					Index:   index,
+					Path:    row.Path,
+					Missing: missing,
+					Snippet: snippet(row.text()),
+				})
+				continue
+			}
+			// A cell spanning into an extra or tag column is the question or answer itself, not a separate value
+			ownCell := func(column int) bool {
+				source := row.source(column)
+				return source != nil && (source == row.source(question) || source == row.source(answer))
+			}
+			for _, column := range extra {
+				if ownCell(column) {
+					continue
+				}
+				if value := row.cell(column); value != "" {
+					flashcard.Answer += definitionSeparator + columnLabel(header, column) + ": " + value
+				}
+			}
+			for _, column := range tagCols {
+				if ownCell(column) {
+					continue
+				}
+				if tag := sanitizeTag(row.cell(column)); tag != "" {
+					flashcard.Tags = append(flashcard.Tags, tag)
+				}
+			}
+			result.Flashcards = append(result.Flashcards, flashcard)
+		}
+		return true
+	})
+	if err != nil {
+		return extraction{}, err
+	}
+	return result, nil
+}
+
+// tableGrid lays out table's rows as a grid, copying cells spanning several columns or rows into every
+// slot they cover. Rows of tables nested inside cells are left out, as are cell contents matching exclude.
+func tableGrid(table *goquery.Selection, exclude string) []tableRow {
+	var rows []tableRow
+	// pending holds cells spanning down from earlier rows, keyed by column, with the rows they still cover
+	type span struct {
+		cell tableCell
+		left int
+	}
+	pending := map[int]span{}
+
+	tableRows(table).Each(func(_ int, tr *goquery.Selection) {
+		head := tr.ParentFiltered("thead").Length() > 0
+		row := tableRow{Header: head, Head: head, Path: domPath(tr.Get(0))}
+		allHeaders := true
+		column := 0
+		fillPending := func() {
+			for {
+				s, ok := pending[column]
+				if !ok {
+					return
+				}
+				row.Cells = append(row.Cells, s.cell)
+				if s.left--; s.left == 0 {
+					delete(pending, column)
+				} else {
+					pending[column] = s
+				}
+				column++
+			}
+		}
+
+		tr.ChildrenFiltered("th, td").Each(func(_ int, td *goquery.Selection) {
+			fillPending()
+			cell := tableCell{Text: collapseWhitespace(textOf(td, exclude)), Header: goquery.NodeName(td) == "th", Source: td.Get(0)}
+			if !cell.Header {
+				allHeaders = false
+			}
+			colspan := spanAttr(td, "colspan", maxColspan)
+			rowspan := spanAttr(td, "rowspan", maxRowspan)
+			for i := 0; i < colspan; i++ {
+				row.Cells = append(row.Cells, cell)
+				if rowspan > 1 {
+					pending[column] = span{cell: cell, left: rowspan - 1}
+				}
+				column++
+			}
+		})
+		fillPending()
+
+		row.Header = row.Header || (allHeaders && len(row.Cells) > 0)
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+// tableRows returns table's own rows, looking through <thead>, <tbody> and <tfoot> but not into nested tables
+func tableRows(table *goquery.Selection) *goquery.Selection {
+	return table.Find("tr").FilterFunction(func(_ int, tr *goquery.Selection) bool {
+		return tr.Closest("table").IsSelection(table)
+	})
+}
+
+// tableHeader returns the text of each column in the last <thead> row or, without a <thead>, in the last of
+// the <th> rows leading the table, or nil when the table has none. Later <th> rows (EX: a group heading in
+// the body) don't name the columns.
+func tableHeader(rows []tableRow) []string {
+	var header []string
+	for _, row := range rows {
+		if row.Head {
+			header = row.texts()
+		}
+	}
+	if header != nil {
+		return header
+	}
+	for _, row := range rows {
+		if !row.Header {
+			break
+		}
+		header = row.texts()
+	}
+	return header
+}
+
+// tableColumn resolves a column given as a 1-based number or as header text to a 0-based index
+func tableColumn(spec string, header []string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 1 {
+			return 0, fmt.Errorf("column %d out of range, columns are numbered from 1", n)
+		}
+		return n - 1, nil
+	}
+	if header == nil {
+		return 0, fmt.Errorf("column %q is named but the table has no header row", spec)
+	}
+	for i, text := range header {
+		if strings.EqualFold(strings.Join(strings.Fields(text), " "), spec) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no column with header %q (headers: %s)", spec, strings.Join(header, ", "))
+}
+
+// tableColumns resolves every column spec with tableColumn
+func tableColumns(specs []string, header []string) ([]int, error) {
+	columns := make([]int, 0, len(specs))
+	for _, spec := range specs {
+		column, err := tableColumn(spec, header)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// columnLabel names a column by its header text, or by its number when the table has no header
+func columnLabel(header []string, column int) string {
+	if column < len(header) && header[column] != "" {
+		return header[column]
+	}
+	return "Column " + strconv.Itoa(column+1)
+}
+
+// cell returns the text in the given column, or "" when the row is too short
+func (r tableRow) cell(column int) string {
+	if column < len(r.Cells) {
+		return r.Cells[column].Text
+	}
+	return ""
+}
+
+// source returns the element the given column's slot came from, or nil when the row is too short
+func (r tableRow) source(column int) *html.Node {
+	if column < len(r.Cells) {
+		return r.Cells[column].Source
+	}
+	return nil
+}
+
+// texts returns the text of each of the row's cells
+func (r tableRow) texts() []string {
+	texts := make([]string, len(r.Cells))
+	for i, cell := range r.Cells {
+		texts[i] = cell.Text
+	}
+	return texts
+}
+
+// text joins the row's cells for display
+func (r tableRow) text() string {
+	return strings.Join(r.texts(), " | ")
+}
+
+// spanAttr returns a cell's colspan or rowspan capped at limit, treating missing and invalid values as 1
+func spanAttr(s *goquery.Selection, name string, limit int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s.AttrOr(name, "1")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, limit)
+}
@@ -0,0 +1,79 @@
+package url2anki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// flagTable has a header row, a row-spanning group column, a column-spanning note, a nested table and a
+// group heading row in the body
+const flagTable = `<html><body><table class="flags">
+	<thead><tr><th>Group</th><th>Flag</th><th>Default</th><th>Description</th></tr></thead>
+	<tbody>
+		<tr><td rowspan="2">Output</td><td>--output-file</td><td>anki_cards.csv</td><td>The file to export to</td></tr>
+		<tr><td>--format</td><td></td><td>The export format <table><tr><td>nested</td></tr></table></td></tr>
+		<tr><th colspan="4">Logging</th></tr>
+		<tr><td>Debug</td><td>--debug</td><td colspan="2">Enable debug logging</td></tr>
+		<tr><td>Misc</td><td></td><td></td><td>Missing its flag</td></tr>
+	</tbody>
+</table></body></html>`
+
+// TestExtractTables tests column mapping by number and header, spanning cells and skipped rows
+func TestExtractTables(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(flagTable))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	result, err := extract(doc, extractOptions{
+		Mode:           "table",
+		TableSelector:  "table.flags",
+		QuestionColumn: "2",
+		AnswerColumn:   "description",
+		ExtraColumns:   []string{"Default"},
+		TagColumns:     []string{"1"},
+	})
+	if err != nil {
+		t.Fatalf("extract returned an error: %v", err)
+	}
+
+	expected := []Flashcard{
+		{Question: "--output-file", Answer: "The file to export to; Default: anki_cards.csv", Tags: []string{"Output"}},
+		{Question: "--format", Answer: "The export format nested", Tags: []string{"Output"}},
+		{Question: "--debug", Answer: "Enable debug logging", Tags: []string{"Debug"}},
+	}
+	if !reflect.DeepEqual(result.Flashcards, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result.Flashcards)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Index != 4 || result.Skipped[0].Missing != "question" {
+		t.Errorf("Expected row 4 to be skipped for its missing question, got %+v", result.Skipped)
+	}
+
+	if _, err := extract(doc, extractOptions{Mode: "table", TableSelector: "table.flags", AnswerColumn: "Nope"}); err == nil || !strings.Contains(err.Error(), `no column with header "Nope"`) {
+		t.Errorf("Expected an unknown header to be rejected, got %v", err)
+	}
+}
+
+// TestTableGridSpans tests that the header comes from the leading <th> rows and that huge spans are clamped
+func TestTableGridSpans(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table>
+		<tr><th>Term</th><th>Meaning</th></tr>
+		<tr><td colspan="2147483647">Pod</td></tr>
+		<tr><th colspan="2">Group</th></tr>
+		<tr><td rowspan="2147483647">Node</td><td>A machine</td></tr>
+	</table>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	rows := tableGrid(doc.Find("table"), "")
+	if width := len(rows[1].Cells); width != maxColspan {
+		t.Errorf("Expected the colspan to be clamped to %d, got %d", maxColspan, width)
+	}
+	if header := tableHeader(rows); !reflect.DeepEqual(header, []string{"Term", "Meaning"}) {
+		t.Errorf("Expected the leading header row, got %v", header)
+	}
+}
@@ -1,10 +1,11 @@
 package url2anki
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,8 +17,10 @@ import (
 
 // Flashcard represents a single Anki flashcard
 type Flashcard struct {
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	GUID     string   `json:"guid,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
 }
 
 // AnkiSyncRequest represents the request structure to the Anki Sync API
@@ -26,118 +29,190 @@ type AnkiSyncRequest struct {
 	Flashcards []Flashcard `json:"flashcards"`
 }
 
-// run is the main function that orchestrates the workflow of url2anki
-func Run(cmd *cobra.Command, args []string) {
-	inputURL, _ := cmd.Flags().GetString("url")
-	pageURL, _ := url.ParseRequestURI(inputURL)
-	url := pageURL.String()
-	questionSelector, _ := cmd.Flags().GetString("question-selector")
-	answerSelector, _ := cmd.Flags().GetString("answer-selector")
-	outputFile, _ := cmd.Flags().GetString("output-file")
-	preview, _ := cmd.Flags().GetBool("preview")
-
-	// Scrape the flashcards from the provided URL using the specified selectors
-	flashcards, err := scrapeFlashcards(url, questionSelector, answerSelector)
+// Run is the main function that orchestrates the workflow of url2anki. Any failure is returned, so the
+// process exits non-zero and a following pipeline stage never mistakes it for an empty scrape.
+func Run(cmd *cobra.Command, args []string) error {
+	diagnose, _ := cmd.Flags().GetBool("diagnose")
+
+	// Work out where the flashcards go before doing any scraping
+	outputs, err := resolveOutputs(cmd)
+	if err != nil {
+		return err
+	}
+	// Keep stdout clean for the card stream when it is one of the outputs
+	msg := messageWriter(outputs)
+
+	urls, err := sourceURLs(cmd, args)
 	if err != nil {
-		fmt.Println("Error scraping flashcards: ", err)
-		return
+		return err
+	}
+	options := extractOptionsFromFlags(cmd)
+
+	// Add the pages listed in the sitemap, if any
+	entries, err := sitemapPages(cmd, msg)
+	if err != nil {
+		return fmt.Errorf("reading sitemap: %w", err)
+	}
+	urls = appendEntryURLs(urls, entries)
+	if len(urls) == 0 {
+		return errors.New("no page in the sitemap matched --url-pattern and --since")
 	}
 
-	// If preview is enabled, display flashcards as a table and ask for confirmation
-	if preview {
-		fmt.Println("Preview of flashcards:")
-		printFlashcards(flashcards)
-		fmt.Print("Do they look ok? (y/n): ")
-		var response string
-		_, err := fmt.Scanln(&response)
+	// Show how the selectors line up instead of exporting anything
+	if diagnose {
+		return diagnosePages(msg, urls, options)
+	}
+
+	// Scrape every page with the same selectors, carrying on past the ones that fail
+	// Pages the sitemap says have not changed since the previous run need not be fetched again
+	stateFile, _ := cmd.Flags().GetString("state")
+	unchanged, err := unchangedPages(stateFile, entries, options)
+	if err != nil {
+		return fmt.Errorf("loading state file: %w", err)
+	}
+	pages := scrapePages(msg, urls, options, unchanged)
+	if len(pages) == 0 {
+		return errors.New("scraping flashcards: none of the pages could be scraped")
+	}
+
+	return deliverPages(cmd, msg, outputs, pages, options)
+}
+
+// diagnosePages prints the pairing report of every page, headed by its URL when there are several
+func diagnosePages(msg io.Writer, urls []string, options extractOptions) error {
+	for _, pageURL := range urls {
+		if len(urls) > 1 {
+			fmt.Fprintf(msg, "== %s\n", pageURL)
+		}
+		doc, err := fetchDocument(pageURL)
 		if err != nil {
-			fmt.Println("Error getting response from user: ", err)
-			return
+			fmt.Fprintf(msg, "Error scraping %s: %v\n", pageURL, err)
+			continue
 		}
-		if strings.ToLower(response) != "y" {
-			fmt.Println("Aborting.")
-			return
+		report, err := diagnosePairing(prepareDocument(doc, options), options)
+		if err != nil {
+			return fmt.Errorf("diagnosing selectors: %w", err)
 		}
+		report.print(msg)
 	}
+	return nil
+}
 
-	// Export to JSON file
-	if outputFile != "" && strings.HasSuffix(outputFile, ".json") {
-		if err := exportFlashcardsToJSONFile(flashcards, outputFile); err != nil {
-			fmt.Println("Error exporting flashcards to JSON file: ", err)
-			return
-		}
-		fmt.Printf("Flashcards exported to %s\n", outputFile)
-	}
+// errPreviewRejected is returned when the user turns down the previewed flashcards
+var errPreviewRejected = errors.New("the flashcards were not exported")
 
-	// Export to CSV file
-	if outputFile != "" && strings.HasSuffix(outputFile, ".csv") {
-		if err := exportFlashcardsToCSVFile(flashcards, outputFile); err != nil {
-			fmt.Println("Error exporting flashcards to CSV file: ", err)
-			return
-		}
-		fmt.Printf("Flashcards exported to %s\n", outputFile)
+// confirmFlashcards displays the flashcards as a table and asks the user whether to carry on
+func confirmFlashcards(msg io.Writer, flashcards []Flashcard) bool {
+	fmt.Fprintln(msg, "Preview of flashcards:")
+	printFlashcards(msg, flashcards)
+	fmt.Fprint(msg, "Do they look ok? (y/n): ")
+	var response string
+	_, err := fmt.Scanln(&response)
+	if err != nil {
+		fmt.Fprintln(msg, "Error getting response from user: ", err)
+		return false
+	}
+	if strings.ToLower(response) != "y" {
+		return false
 	}
+	return true
 }
 
 // scrapeFlashcards scrapes the flashcards from the provided URL using the provided HTML selectors
 func scrapeFlashcards(url, questionSelector, answerSelector string) ([]Flashcard, error) {
+	doc, err := fetchDocument(url)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parseFieldSelector(questionSelector, defaultFieldSeparator)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := parseFieldSelector(answerSelector, defaultFieldSeparator)
+	if err != nil {
+		return nil, err
+	}
+	flashcards, err := extractFlashcards(doc, question, answer, hiddenSelector)
+	if err != nil {
+		return nil, err
+	}
+	assignGUIDs(flashcards, url, nil)
+	return flashcards, nil
+}
+
+// fetchDocument requests the webpage at url and parses it as HTML
+func fetchDocument(url string) (*goquery.Document, error) {
+	return fetchDocumentContext(context.Background(), url)
+}
+
+// fetchDocumentContext requests the webpage at url and parses it as HTML, giving up when ctx is done
+func fetchDocumentContext(ctx context.Context, url string) (*goquery.Document, error) {
 	// Request the webpage
-	res, err := http.Get(url) //#nosec G107
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req) //#nosec G107
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return nil, errors.New("failed to fetch the URL")
+		return nil, fmt.Errorf("failed to fetch the URL: %s", res.Status)
 	}
 
 	// Parse the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	return goquery.NewDocumentFromReader(res.Body)
+}
+
+// extractFlashcards pairs the questions and answers found in doc using the provided selectors,
+// leaving out any of their descendants matching exclude
+func extractFlashcards(doc *goquery.Document, question, answer fieldSelector, exclude string) ([]Flashcard, error) {
+	// Find the questions and answers using the specified selectors
+	questions, err := question.all(doc.Selection, exclude)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := answer.all(doc.Selection, exclude)
 	if err != nil {
 		return nil, err
 	}
 
-	// Find the questions and answers using the specified selectors
-	questions := doc.Find(questionSelector)
-	answers := doc.Find(answerSelector)
-
-	if questions.Length() != answers.Length() {
-		return nil, errors.New("the number of questions and answers do not match")
+	if len(questions) != len(answers) {
+		return nil, fmt.Errorf("the number of questions (%d) and answers (%d) do not match; rerun with --diagnose to see where they diverge, or --tolerant to pair each question with the next answer", len(questions), len(answers))
 	}
 
-	// Create flashcards by pairing questions and answers
+	// Create flashcards by pairing questions and answers, whose text is already cleaned up
 	var flashcards []Flashcard
-	questions.Each(func(i int, s *goquery.Selection) {
-		// Clean up the question by removing newlines and trimming whitespace
-		question := strings.ReplaceAll(s.Text(), "\n", "")
-		question = strings.ReplaceAll(question, "\r\n", "")
-		question = strings.TrimSpace(question)
-
-		// Clean up the answer by removing newlines and trimming whitespace
-		answer := answers.Eq(i).Text()
-		answer = strings.ReplaceAll(answer, "\n", "")
-		answer = strings.ReplaceAll(answer, "\r\n", "")
-		answer = strings.TrimSpace(answer)
-
+	for i := range questions {
 		flashcards = append(flashcards, Flashcard{
-			Question: question,
-			Answer:   answer,
+			Question: questions[i].Text,
+			Answer:   answers[i].Text,
 		})
-	})
+	}
 
 	return flashcards, nil
 }
 
+// pageTitle returns the page's <title>, falling back to its host name when the title is empty
+func pageTitle(doc *goquery.Document, pageURL *url.URL) string {
+	title := strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+	if title == "" {
+		return pageURL.Hostname()
+	}
+	return title
+}
+
 // printFlashcards displays the flashcards as a table on the CLI
-func printFlashcards(flashcards []Flashcard) {
-	fmt.Println("+-----------------------------+-----------------------------+")
-	fmt.Println("|           Question           |           Answer            |")
-	fmt.Println("+-----------------------------+-----------------------------+")
+func printFlashcards(w io.Writer, flashcards []Flashcard) {
+	fmt.Fprintln(w, "+-----------------------------+-----------------------------+")
+	fmt.Fprintln(w, "|           Question           |           Answer            |")
+	fmt.Fprintln(w, "+-----------------------------+-----------------------------+")
 	for _, flashcard := range flashcards {
-		fmt.Printf("| %-27s | %-27s |\n", flashcard.Question, flashcard.Answer)
+		fmt.Fprintf(w, "| %-27s | %-27s |\n", flashcard.Question, flashcard.Answer)
 	}
-	fmt.Println("+-----------------------------+-----------------------------+")
+	fmt.Fprintln(w, "+-----------------------------+-----------------------------+")
 }
 
 // exportFlashcardsToJSONFile exports the flashcards to a JSON file
@@ -149,30 +224,7 @@ func exportFlashcardsToJSONFile(flashcards []Flashcard, filename string) error {
 	return os.WriteFile(filename, data, 0600) // #nosec G304 G703 -- filename from user CLI arg, expected
 }
 
-// exportFlashcardsToCSVFile exports the flashcards to a JSON file
+// exportFlashcardsToCSVFile exports the flashcards to a CSV file
 func exportFlashcardsToCSVFile(flashcards []Flashcard, filename string) error {
-	file, err := os.Create(filename) //#nosec G304
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	err = writer.Write([]string{"Question", "Answer"})
-	if err != nil {
-		return err
-	}
-
-	// Write flashcard data
-	for _, flashcard := range flashcards {
-		err := writer.Write([]string{flashcard.Question, flashcard.Answer})
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return exportFlashcardsToCSVFileWithDialect(flashcards, filename, defaultCSVDialect)
 }
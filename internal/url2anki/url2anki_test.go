@@ -134,3 +134,30 @@ func TestExportFlashcardsToCSVFile(t *testing.T) {
 		}
 	}
 }
+
+// TestExportFlashcardsToCSVFileWithGUID tests that exportFlashcardsToCSVFile adds a GUID column when cards carry GUIDs
+func TestExportFlashcardsToCSVFileWithGUID(t *testing.T) {
+	flashcards := []Flashcard{
+		{Question: "Question 1", Answer: "Answer 1", GUID: "guid1"},
+	}
+
+	tmpfile, err := os.CreateTemp("", "flashcards*.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if err := exportFlashcardsToCSVFile(flashcards, tmpfile.Name()); err != nil {
+		t.Fatalf("exportFlashcardsToCSV returned an error: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the file: %v", err)
+	}
+
+	expected := "Question,Answer,GUID\nQuestion 1,Answer 1,guid1\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
@@ -22,7 +22,7 @@
 //
 //	func main() {
 //		conf := config.GetEnvVars()
-//		fmt.Printf("URL: %s\n", conf.URL)
+//		fmt.Printf("URLs: %v\n", conf.URLs)
 //	}
 package config
 
@@ -44,9 +44,55 @@ import (
 //
 // Configuration options:
 //   - URL: The URL to scrape for flashcards
+//   - URLs: Further URLs to scrape for flashcards
+//   - URLFile: The file listing further URLs to scrape, one per line
+//   - Sitemap: The sitemap listing further URLs to scrape
+//   - URLPattern: The regular expression picking the sitemap URLs to scrape
+//   - Since: How recently a sitemap entry must have changed to be scraped
+//   - StartURLs: The pages a crawl starts from
+//   - Follow: The HTML selector for the links a crawl follows
+//   - MaxDepth: How many links away from the start pages a crawl goes
+//   - MaxPages: The most pages a crawl fetches
+//   - SameHost: Whether a crawl stays on the hosts of its start pages
+//   - Checkpoint: The file recording a crawl's progress so it can be resumed
+//   - Resume: Whether to continue the crawl recorded in the checkpoint file
+//   - Mode: The strategy used to pick flashcards out of the page
 //   - QuestionSelector: The HTML selector for questions
 //   - AnswerSelector: The HTML selector for answers
+//   - GUIDSelector: The HTML selector whose text keys each flashcard's GUID
+//   - ItemSelector: The HTML selector for the container element of each flashcard
+//   - Tolerant: Whether to pair each question with the next answer in document order
+//   - TableSelector: The HTML selector for the tables read in table mode
+//   - QuestionColumn: The table column holding questions
+//   - AnswerColumn: The table column holding answers
+//   - ExtraColumns: The table columns appended to answers
+//   - TagColumns: The table columns turned into tags
+//   - HeadingSelector: The headings turned into questions in sections mode
+//   - LinkSelector: The HTML selector for the index links whose text becomes each question
+//   - DetailAnswerSelector: The HTML selector for the answer on each linked page
+//   - DetailConcurrency: How many linked pages are fetched at once
+//   - MainContent: Whether to strip navigation, footers and other boilerplate before extracting
+//   - FieldSeparator: The separator joining the parts of a selector
+//   - Excludes: The HTML selectors for elements left out of questions and answers
+//   - KeepHidden: Whether to keep hidden elements in questions and answers
 //   - OutputFile: The filename to export flashcards to
+//   - Outputs: Additional filenames to export flashcards to
+//   - Format: The export format overriding detection by file extension
+//   - Delimiter: The field delimiter for CSV and text exports
+//   - Quote: The quoting style for CSV and text exports
+//   - BOM: Whether to start CSV and text exports with a UTF-8 byte order mark
+//   - NoHeader: Whether to leave out the header row of CSV and text exports
+//   - Deck: The name of the Anki deck to export flashcards into
+//   - Collection: The existing Anki collection.anki2 file to write flashcards into
+//   - AnkiConnect: The AnkiConnect endpoint to push flashcards to
+//   - UpdateExisting: Whether to update existing notes whose answer changed
+//   - SyncServer: The self-hosted Anki sync server to upload flashcards to
+//   - ForceFullUpload: Whether the sync server upload may replace the server's whole collection
+//   - SyncUser: The username to log in to the sync server with
+//   - SyncPassword: The password to log in to the sync server with
+//   - StateFile: The file remembering the cards of previous runs
+//   - OnlyChanges: Whether to export only added and changed cards
+//   - TagChanges: Whether to tag changed and removed cards
 //   - Preview: Whether to preview flashcards before exporting
 //   - Debug: Whether to enable debug-level logging
 type Config struct {
@@ -54,6 +100,64 @@ type Config struct {
 	// It is loaded from the URL2ANKI_URL environment variable.
 	URL string `env:"URL2ANKI_URL"`
 
+	// URLs specifies the URLs to scrape for flashcards with the same selectors, starting with URL.
+	// It is loaded from the whitespace-separated URL2ANKI_URLS environment variable, since commas
+	// can appear in URLs (EX: ?ids=1,2).
+	URLs []string `env:"URL2ANKI_URLS" envSeparator:" "`
+
+	// URLFile specifies a file listing further URLs to scrape, one per line.
+	// It is loaded from the URL2ANKI_URL_FILE environment variable.
+	// Blank lines and lines starting with # are ignored.
+	URLFile string `env:"URL2ANKI_URL_FILE"`
+
+	// Sitemap specifies a sitemap.xml, sitemap index or gzipped sitemap listing further URLs to scrape.
+	// It is loaded from the URL2ANKI_SITEMAP environment variable.
+	Sitemap string `env:"URL2ANKI_SITEMAP"`
+
+	// URLPattern specifies the regular expression a sitemap URL must match to be scraped.
+	// It is loaded from the URL2ANKI_URL_PATTERN environment variable.
+	URLPattern string `env:"URL2ANKI_URL_PATTERN"`
+
+	// Since specifies how recently a sitemap entry's lastmod must be for it to be scraped,
+	// as a date, a timestamp or a duration back from now such as 7d.
+	// It is loaded from the URL2ANKI_SINCE environment variable.
+	Since string `env:"URL2ANKI_SINCE"`
+
+	// StartURLs specifies the pages a crawl starts from.
+	// It is loaded from the comma-separated URL2ANKI_START environment variable.
+	StartURLs []string `env:"URL2ANKI_START" envSeparator:","`
+
+	// Follow specifies the HTML selector for the links a crawl follows from each page.
+	// It is loaded from the URL2ANKI_FOLLOW environment variable.
+	// Pagination links marked rel="next" are always followed.
+	Follow string `env:"URL2ANKI_FOLLOW"`
+
+	// MaxDepth specifies how many links away from the start pages a crawl goes.
+	// It is loaded from the URL2ANKI_MAX_DEPTH environment variable.
+	MaxDepth int `env:"URL2ANKI_MAX_DEPTH" envDefault:"3"`
+
+	// MaxPages specifies the most pages a crawl fetches, with 0 meaning no limit.
+	// It is loaded from the URL2ANKI_MAX_PAGES environment variable.
+	MaxPages int `env:"URL2ANKI_MAX_PAGES" envDefault:"200"`
+
+	// SameHost specifies whether a crawl only follows links to the hosts of its start pages.
+	// It is loaded from the URL2ANKI_SAME_HOST environment variable.
+	SameHost bool `env:"URL2ANKI_SAME_HOST"`
+
+	// Checkpoint specifies the file recording a crawl's queued and visited pages and the cards collected so far.
+	// It is loaded from the URL2ANKI_CHECKPOINT environment variable.
+	// Checkpointing is disabled when empty.
+	Checkpoint string `env:"URL2ANKI_CHECKPOINT"`
+
+	// Resume specifies whether to continue the crawl recorded in the checkpoint file instead of starting over.
+	// It is loaded from the URL2ANKI_RESUME environment variable.
+	Resume bool `env:"URL2ANKI_RESUME"`
+
+	// Mode specifies the strategy used to pick flashcards out of the page.
+	// It is loaded from the URL2ANKI_MODE environment variable.
+	// The default, selectors, pairs the elements matched by the question and answer selectors.
+	Mode string `env:"URL2ANKI_MODE" envDefault:"selectors"`
+
 	// QuestionSelector specifies the HTML selector for questions.
 	// It is loaded from the URL2ANKI_QUESTION_SELECTOR environment variable.
 	QuestionSelector string `env:"URL2ANKI_QUESTION_SELECTOR"`
@@ -62,11 +166,159 @@ type Config struct {
 	// It is loaded from the URL2ANKI_ANSWER_SELECTOR environment variable.
 	AnswerSelector string `env:"URL2ANKI_ANSWER_SELECTOR"`
 
+	// GUIDSelector specifies the HTML selector whose text keys each flashcard's GUID.
+	// It is loaded from the URL2ANKI_GUID_SELECTOR environment variable.
+	// When empty, GUIDs are keyed by the normalized question.
+	GUIDSelector string `env:"URL2ANKI_GUID_SELECTOR"`
+
+	// ItemSelector specifies the HTML selector for the container element of each flashcard.
+	// It is loaded from the URL2ANKI_ITEM_SELECTOR environment variable.
+	// When set, the question, answer and GUID selectors are evaluated inside each container.
+	ItemSelector string `env:"URL2ANKI_ITEM_SELECTOR"`
+
+	// Tolerant specifies whether to pair each question with the next answer in document order.
+	// It is loaded from the URL2ANKI_TOLERANT environment variable.
+	// Questions without an answer and answers without a question are skipped and reported.
+	Tolerant bool `env:"URL2ANKI_TOLERANT"`
+
+	// TableSelector specifies the HTML selector for the tables read in table mode.
+	// It is loaded from the URL2ANKI_TABLE_SELECTOR environment variable.
+	TableSelector string `env:"URL2ANKI_TABLE_SELECTOR" envDefault:"table"`
+
+	// QuestionColumn specifies the table column holding questions, as a 1-based number or header text.
+	// It is loaded from the URL2ANKI_QUESTION_COL environment variable.
+	QuestionColumn string `env:"URL2ANKI_QUESTION_COL" envDefault:"1"`
+
+	// AnswerColumn specifies the table column holding answers, as a 1-based number or header text.
+	// It is loaded from the URL2ANKI_ANSWER_COL environment variable.
+	AnswerColumn string `env:"URL2ANKI_ANSWER_COL" envDefault:"2"`
+
+	// ExtraColumns specifies the table columns appended to answers as "Header: value".
+	// It is loaded from the comma-separated URL2ANKI_EXTRA_COLS environment variable.
+	ExtraColumns []string `env:"URL2ANKI_EXTRA_COLS" envSeparator:","`
+
+	// TagColumns specifies the table columns whose values become tags.
+	// It is loaded from the comma-separated URL2ANKI_TAG_COLS environment variable.
+	TagColumns []string `env:"URL2ANKI_TAG_COLS" envSeparator:","`
+
+	// HeadingSelector specifies the headings turned into questions in sections mode.
+	// It is loaded from the URL2ANKI_HEADING environment variable.
+	HeadingSelector string `env:"URL2ANKI_HEADING" envDefault:"h2,h3"`
+
+	// LinkSelector specifies the HTML selector for the links on an index page whose text becomes each question.
+	// It is loaded from the URL2ANKI_LINK_SELECTOR environment variable.
+	// When set, answers are read from the page each link points to with DetailAnswerSelector.
+	LinkSelector string `env:"URL2ANKI_LINK_SELECTOR"`
+
+	// DetailAnswerSelector specifies the HTML selector for the answer on each page an index link points to.
+	// It is loaded from the URL2ANKI_DETAIL_ANSWER_SELECTOR environment variable.
+	DetailAnswerSelector string `env:"URL2ANKI_DETAIL_ANSWER_SELECTOR"`
+
+	// DetailConcurrency specifies how many linked pages are fetched at once.
+	// It is loaded from the URL2ANKI_DETAIL_CONCURRENCY environment variable.
+	DetailConcurrency int `env:"URL2ANKI_DETAIL_CONCURRENCY" envDefault:"4"`
+
+	// MainContent specifies whether to strip navigation, footers and other boilerplate before extracting.
+	// It is loaded from the URL2ANKI_MAIN_CONTENT environment variable.
+	// The page's main article content is isolated first, so broad selectors like p or li stay inside it.
+	MainContent bool `env:"URL2ANKI_MAIN_CONTENT"`
+
+	// FieldSeparator specifies the separator joining the values of a selector's "&&"-separated parts.
+	// It is loaded from the URL2ANKI_FIELD_SEPARATOR environment variable.
+	FieldSeparator string `env:"URL2ANKI_FIELD_SEPARATOR" envDefault:" "`
+
+	// Excludes specifies the HTML selectors for elements left out of questions and answers.
+	// It is loaded from the semicolon-separated URL2ANKI_EXCLUDE environment variable,
+	// since selectors themselves may contain commas.
+	Excludes []string `env:"URL2ANKI_EXCLUDE" envSeparator:";"`
+
+	// KeepHidden specifies whether to keep hidden elements in questions and answers.
+	// It is loaded from the URL2ANKI_KEEP_HIDDEN environment variable.
+	// By default elements marked hidden, aria-hidden or screen-reader-only are left out.
+	KeepHidden bool `env:"URL2ANKI_KEEP_HIDDEN"`
+
 	// OutputFile specifies the filename to export flashcards to.
 	// It is loaded from the URL2ANKI_OUTPUT_FILE environment variable.
-	// Defaults to "./anki_cards.csv" if not set.
+	// Defaults to "./anki_cards.csv" if not set, which is only written when no other destination is chosen.
 	OutputFile string `env:"URL2ANKI_OUTPUT_FILE" envDefault:"./anki_cards.csv"`
 
+	// Outputs specifies additional filenames to export flashcards to in a single run.
+	// It is loaded from the comma-separated URL2ANKI_OUTPUTS environment variable.
+	// When set, OutputFile is only used if it was given explicitly on the command line.
+	Outputs []string `env:"URL2ANKI_OUTPUTS" envSeparator:","`
+
+	// Format specifies the export format, overriding detection by file extension.
+	// It is loaded from the URL2ANKI_FORMAT environment variable.
+	Format string `env:"URL2ANKI_FORMAT"`
+
+	// Delimiter specifies the field delimiter for CSV and text exports, as a single character
+	// or one of tab, comma, semicolon, space, pipe and colon.
+	// It is loaded from the URL2ANKI_DELIMITER environment variable.
+	// When empty, CSV files use commas and text files use tabs.
+	Delimiter string `env:"URL2ANKI_DELIMITER"`
+
+	// Quote specifies the quoting style for CSV and text exports: minimal, all or none.
+	// It is loaded from the URL2ANKI_QUOTE environment variable.
+	Quote string `env:"URL2ANKI_QUOTE" envDefault:"minimal"`
+
+	// BOM specifies whether CSV and text exports start with a UTF-8 byte order mark.
+	// It is loaded from the URL2ANKI_BOM environment variable.
+	BOM bool `env:"URL2ANKI_BOM"`
+
+	// NoHeader specifies whether the header row of CSV and text exports is left out.
+	// It is loaded from the URL2ANKI_NO_HEADER environment variable.
+	NoHeader bool `env:"URL2ANKI_NO_HEADER"`
+
+	// Deck specifies the name of the Anki deck to export flashcards into.
+	// It is loaded from the URL2ANKI_DECK environment variable.
+	// When empty, the deck is named after the scraped page's title.
+	Deck string `env:"URL2ANKI_DECK"`
+
+	// Collection specifies an existing Anki collection.anki2 file to write flashcards into.
+	// It is loaded from the URL2ANKI_COLLECTION environment variable.
+	// Writing to a collection is disabled when empty.
+	Collection string `env:"URL2ANKI_COLLECTION"`
+
+	// AnkiConnect specifies the AnkiConnect endpoint to push flashcards to.
+	// It is loaded from the URL2ANKI_ANKI_CONNECT environment variable.
+	// Pushing is disabled when empty.
+	AnkiConnect string `env:"URL2ANKI_ANKI_CONNECT"`
+
+	// UpdateExisting specifies whether existing notes whose answer changed are updated.
+	// It is loaded from the URL2ANKI_UPDATE_EXISTING environment variable.
+	UpdateExisting bool `env:"URL2ANKI_UPDATE_EXISTING"`
+
+	// SyncServer specifies the self-hosted Anki sync server to upload flashcards to.
+	// It is loaded from the URL2ANKI_SYNC_SERVER environment variable.
+	// Syncing is disabled when empty.
+	SyncServer string `env:"URL2ANKI_SYNC_SERVER"`
+
+	// ForceFullUpload specifies whether uploading to the sync server may replace the server's whole collection.
+	// It is loaded from the URL2ANKI_FORCE_FULL_UPLOAD environment variable.
+	// Syncing to a sync server is refused unless it is set.
+	ForceFullUpload bool `env:"URL2ANKI_FORCE_FULL_UPLOAD"`
+
+	// SyncUser specifies the username to log in to the sync server with.
+	// It is loaded from the URL2ANKI_SYNC_USER environment variable.
+	SyncUser string `env:"URL2ANKI_SYNC_USER"`
+
+	// SyncPassword specifies the password to log in to the sync server with.
+	// It is loaded from the URL2ANKI_SYNC_PASSWORD environment variable.
+	SyncPassword string `env:"URL2ANKI_SYNC_PASSWORD"`
+
+	// StateFile specifies the file remembering the cards of previous runs, used to report what changed upstream.
+	// It is loaded from the URL2ANKI_STATE environment variable.
+	// Incremental scraping is disabled when empty.
+	StateFile string `env:"URL2ANKI_STATE"`
+
+	// OnlyChanges specifies whether to export only the cards added or changed since the previous run.
+	// It is loaded from the URL2ANKI_ONLY_CHANGES environment variable.
+	OnlyChanges bool `env:"URL2ANKI_ONLY_CHANGES"`
+
+	// TagChanges specifies whether to tag changed cards and the existing notes of removed cards.
+	// It is loaded from the URL2ANKI_TAG_CHANGES environment variable.
+	TagChanges bool `env:"URL2ANKI_TAG_CHANGES"`
+
 	// Preview specifies whether to preview flashcards before exporting.
 	// It is loaded from the URL2ANKI_PREVIEW environment variable.
 	Preview bool `env:"URL2ANKI_PREVIEW"`
@@ -109,8 +361,8 @@ type Config struct {
 //	conf := config.GetEnvVars()
 //
 //	// Use configuration
-//	if conf.URL != "" {
-//		fmt.Printf("Scraping URL: %s\n", conf.URL)
+//	for _, url := range conf.URLs {
+//		fmt.Printf("Scraping URL: %s\n", url)
 //	}
 func GetEnvVars() Config {
 	// Get current working directory for secure file operations
@@ -154,6 +406,11 @@ func GetEnvVars() Config {
 		fmt.Printf("Error parsing environment variables: %s\n", err)
 		os.Exit(1)
 	}
+	// The --url flag collects every URL, so the single URL leads the list and tabs or newlines also separate URLs
+	conf.URLs = strings.Fields(strings.Join(conf.URLs, " "))
+	if conf.URL != "" {
+		conf.URLs = append([]string{conf.URL}, conf.URLs...)
+	}
 
 	return conf
 }